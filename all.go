@@ -0,0 +1,41 @@
+package hashstructure
+
+import (
+	"reflect"
+)
+
+// HashAll returns the hash of the values vs in order, as if they were the
+// elements of a slice. Each value is hashed as a top-level value, so options
+// such as OnlyFields apply to each of them.
+//
+// The options are the same as for Hash.
+func HashAll(opts *HashOptions, vs ...interface{}) (uint64, error) {
+	w, err := newWalker(opts)
+	if err != nil {
+		return 0, err
+	}
+	var h uint64
+	for _, v := range vs {
+		current, err := w.visit(reflect.ValueOf(v), visitOpts{Flags: visitFlagRoot})
+		if err != nil {
+			return 0, err
+		}
+		h = w.hashUpdateOrdered(h, current)
+	}
+	return w.final(h), w.finish(nil)
+}
+
+// HashAllUnordered returns the hash of the set of values vs, like a
+// SetHasher with every value added to it. The result doesn't depend on the
+// order of the values, but duplicate values are counted.
+//
+// The options are the same as for Hash.
+func HashAllUnordered(opts *HashOptions, vs ...interface{}) (uint64, error) {
+	s := NewSetHasher(opts)
+	for _, v := range vs {
+		if err := s.AddElement(v); err != nil {
+			return 0, err
+		}
+	}
+	return s.Sum64(), nil
+}
@@ -0,0 +1,158 @@
+package hashstructure
+
+import (
+	"testing"
+)
+
+func TestHashAll(t *testing.T) {
+	cases := []struct {
+		One, Two []interface{}
+		Match    bool
+	}{
+		{
+			[]interface{}{"a", 1},
+			[]interface{}{"a", 1},
+			true,
+		},
+
+		{
+			[]interface{}{"a", 1},
+			[]interface{}{1, "a"},
+			false,
+		},
+
+		{
+			[]interface{}{"a"},
+			[]interface{}{"a", "a"},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := HashAll(nil, tc.One...)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := HashAll(nil, tc.Two...)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHashAll_onlyFields(t *testing.T) {
+	type Test struct {
+		Name string
+		Age  int
+	}
+
+	opts := &HashOptions{OnlyFields: []string{"Name"}}
+	one, err := HashAll(opts, Test{Name: "foo", Age: 1}, Test{Name: "bar", Age: 2})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := HashAll(opts, Test{Name: "foo", Age: 3}, Test{Name: "bar", Age: 4})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatal("OnlyFields should apply to each value")
+	}
+
+	if _, err := HashAll(opts, Test{}, 1); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHashAllUnordered(t *testing.T) {
+	cases := []struct {
+		One, Two []interface{}
+		Match    bool
+	}{
+		{
+			[]interface{}{"a", 1},
+			[]interface{}{1, "a"},
+			true,
+		},
+
+		{
+			[]interface{}{"a", "b", "c"},
+			[]interface{}{"c", "a", "b"},
+			true,
+		},
+
+		// Duplicates are counted, not canceled
+		{
+			[]interface{}{"a", "a", "b"},
+			[]interface{}{"b"},
+			false,
+		},
+
+		{
+			[]interface{}{"a", "a"},
+			[]interface{}{"a"},
+			false,
+		},
+
+		{
+			[]interface{}{"a", "a", "b"},
+			[]interface{}{"a", "b", "a"},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := HashAllUnordered(nil, tc.One...)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := HashAllUnordered(nil, tc.Two...)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHashAllUnordered_onlyFields(t *testing.T) {
+	type Test struct {
+		Name string
+		Age  int
+	}
+
+	opts := &HashOptions{OnlyFields: []string{"Name"}}
+	one, err := HashAllUnordered(opts, Test{Name: "foo", Age: 1}, Test{Name: "bar", Age: 2})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := HashAllUnordered(opts, Test{Name: "bar", Age: 4}, Test{Name: "foo", Age: 3})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatal("OnlyFields should apply to each value")
+	}
+
+	if _, err := HashAllUnordered(opts, Test{}, 1); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,69 @@
+package hashstructure
+
+import (
+	"fmt"
+	"math/bits"
+	"reflect"
+)
+
+// HashBucketed returns two hash values of v, which must be a struct or a
+// pointer to one. The full hash is the same as Hash. The bucket hash only
+// covers the top-level fields tagged hash:"bucket", so values that agree on
+// those fields share a bucket even if the rest of their fields differ. This
+// is useful to group near-duplicates before comparing them in full.
+//
+// The options are the same as for Hash.
+func HashBucketed(v interface{}, opts *HashOptions) (bucket uint64, full uint64, err error) {
+	if opts == nil {
+		opts = &HashOptions{}
+	}
+
+	full, err = Hash(v, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return 0, 0, fmt.Errorf("hashstructure: HashBucketed requires a struct, got %s", rv.Kind())
+	}
+
+	w, err := newWalker(opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	w.onlyFields = make(map[string]struct{})
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get(w.tag) == "bucket" {
+			w.onlyFields[t.Field(i).Name] = struct{}{}
+		}
+	}
+
+	bucket, err = w.visit(rv, visitOpts{Flags: visitFlagRoot})
+	if err != nil {
+		return 0, 0, err
+	}
+	if err = w.finish(nil); err != nil {
+		return 0, 0, err
+	}
+	return w.final(bucket), full, nil
+}
+
+// ShardIndex maps the hash value h to one of the given number of shards,
+// such as to pick the shard of a sharded map keyed by hash values. Unlike
+// h % shards, every shard gets an equal share of the hash values, up to
+// rounding, and it depends on the high bits of h rather than the low bits.
+// It panics if shards isn't positive.
+func ShardIndex(h uint64, shards int) int {
+	if shards <= 0 {
+		panic(fmt.Sprintf("hashstructure: ShardIndex requires a positive number of shards, got %d", shards))
+	}
+
+	// The high word of h * shards is h scaled down to [0, shards)
+	hi, _ := bits.Mul64(h, uint64(shards))
+	return int(hi)
+}
@@ -0,0 +1,125 @@
+package hashstructure
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHashBucketed(t *testing.T) {
+	type Record struct {
+		Country string `hash:"bucket"`
+		City    string `hash:"bucket"`
+		Street  string
+		Number  int
+	}
+
+	cases := []struct {
+		One, Two    interface{}
+		BucketMatch bool
+		FullMatch   bool
+	}{
+		{
+			Record{Country: "US", City: "SF", Street: "Market", Number: 1},
+			Record{Country: "US", City: "SF", Street: "Mission", Number: 2},
+			true,
+			false,
+		},
+		{
+			Record{Country: "US", City: "SF", Street: "Market", Number: 1},
+			&Record{Country: "US", City: "SF", Street: "Market", Number: 1},
+			true,
+			true,
+		},
+		{
+			Record{Country: "US", City: "SF", Street: "Market", Number: 1},
+			Record{Country: "US", City: "LA", Street: "Market", Number: 1},
+			false,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		oneBucket, oneFull, err := HashBucketed(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		twoBucket, twoFull, err := HashBucketed(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if oneBucket == 0 || oneFull == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		if (oneBucket == twoBucket) != tc.BucketMatch {
+			t.Fatalf("bad bucket, expected: %#v\n\n%#v\n\n%#v", tc.BucketMatch, tc.One, tc.Two)
+		}
+		if (oneFull == twoFull) != tc.FullMatch {
+			t.Fatalf("bad full, expected: %#v\n\n%#v\n\n%#v", tc.FullMatch, tc.One, tc.Two)
+		}
+	}
+
+	// The full hash is the same as Hash
+	v := Record{Country: "US", City: "SF", Street: "Market", Number: 1}
+	_, full, err := HashBucketed(v, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected, err := Hash(v, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if full != expected {
+		t.Fatalf("full hash %d doesn't match Hash %d", full, expected)
+	}
+
+	if _, _, err := HashBucketed("foo", nil); err == nil {
+		t.Fatal("expected error for non-struct")
+	}
+}
+
+func TestShardIndex(t *testing.T) {
+	for _, shards := range []int{1, 2, 7, 16, 100} {
+		if i := ShardIndex(0, shards); i != 0 {
+			t.Fatalf("bad: %d shards, 0 is in shard %d", shards, i)
+		}
+		if i := ShardIndex(math.MaxUint64, shards); i != shards-1 {
+			t.Fatalf("bad: %d shards, max is in shard %d", shards, i)
+		}
+
+		// Each shard gets an equal range of the hash values
+		width := math.MaxUint64/uint64(shards) + 1
+		for i := 0; i < shards; i++ {
+			if got := ShardIndex(uint64(i)*width, shards); got != i {
+				t.Fatalf("bad: %d shards, start of range %d is in shard %d", shards, i, got)
+			}
+		}
+
+		// The hash values of similar values spread over the shards
+		const n = 10000
+		counts := make([]int, shards)
+		for i := 0; i < n*shards; i++ {
+			h, err := Hash(i, nil)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			counts[ShardIndex(h, shards)]++
+		}
+		for i, count := range counts {
+			if count < n*9/10 || count > n*11/10 {
+				t.Fatalf("bad: %d shards, shard %d has %d of %d values", shards, i, count, n*shards)
+			}
+		}
+	}
+}
+
+func TestShardIndex_invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	ShardIndex(1, 0)
+}
@@ -0,0 +1,59 @@
+package hashstructure
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Canonical returns a deterministic textual representation of exactly what
+// is hashed for v by Hash with the same options. This is useful to find out
+// why two values hash differently by diffing their canonical text.
+//
+// Ordered values (slices and arrays) are written in order as "[a, b]".
+// Unordered values are written with their entries sorted: sets as
+// "set[a, b]", maps as "map{k: v}" and structs as "Name{Field: v}". Fields
+// and entries that are excluded from the hash are omitted.
+func Canonical(v interface{}, opts *HashOptions) (string, error) {
+	w, err := newWalker(opts)
+	if err != nil {
+		return "", err
+	}
+	w.canonical = true
+	w.jsonFast = w.jsonFastPath()
+	_, err = w.visit(reflect.ValueOf(v), visitOpts{Flags: visitFlagRoot})
+	if err = w.finish(err); err != nil {
+		return "", err
+	}
+	return w.text, nil
+}
+
+// canonicalNumber returns the canonical text of a bool or numeric value.
+func canonicalNumber(v reflect.Value) string {
+	var s string
+	switch v.Kind() {
+	case reflect.Bool:
+		s = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		s = strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		s = strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Complex64:
+		s = strconv.FormatComplex(v.Complex(), 'g', -1, 64)
+	}
+	return v.Kind().String() + "(" + s + ")"
+}
+
+// canonicalList joins the canonical texts of the elements of a value,
+// sorting them first if the value is unordered.
+func canonicalList(open string, texts []string, close string, unordered bool) string {
+	if unordered {
+		sort.Strings(texts)
+	}
+	return open + strings.Join(texts, ", ") + close
+}
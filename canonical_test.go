@@ -0,0 +1,70 @@
+package hashstructure
+
+import (
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	type Inner struct {
+		Enabled bool
+	}
+
+	type Test struct {
+		Name    string
+		UUID    string   `hash:"ignore"`
+		Friends []string `hash:"set"`
+		Scores  []int
+		Meta    map[string]interface{}
+		Inner   *Inner
+	}
+
+	cases := []struct {
+		Value    interface{}
+		Expected string
+	}{
+		{
+			Test{
+				Name:    "foo",
+				UUID:    "ignored",
+				Friends: []string{"mitchellh", "bmoylan"},
+				Scores:  []int{3, 1},
+				Meta:    map[string]interface{}{"b": 1.5, "a": uint8(2)},
+				Inner:   &Inner{Enabled: true},
+			},
+			`Test{Friends: set["bmoylan", "mitchellh"], Inner: Inner{Enabled: bool(true)}, ` +
+				`Meta: map{"a": uint8(2), "b": float64(1.5)}, Name: "foo", Scores: [int(3), int(1)]}`,
+		},
+		{
+			[]interface{}{nil, "foo"},
+			`[nil(interface {}), "foo"]`,
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := Canonical(tc.Value, nil)
+		if err != nil {
+			t.Fatalf("Failed to get canonical text of %#v: %s", tc.Value, err)
+		}
+		if actual != tc.Expected {
+			t.Fatalf("bad canonical text of %#v\n\ngot:      %s\nexpected: %s", tc.Value, actual, tc.Expected)
+		}
+	}
+}
+
+func TestCanonical_setOrder(t *testing.T) {
+	type Test struct {
+		Friends []string `hash:"set"`
+	}
+
+	one, err := Canonical(Test{Friends: []string{"foo", "bar"}}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Canonical(Test{Friends: []string{"bar", "foo"}}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatalf("expected equal canonical text:\n\n%s\n\n%s", one, two)
+	}
+}
@@ -0,0 +1,46 @@
+package hashstructure
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// HashComparable returns the hash value of v like Hash with default
+// options. Booleans, numbers and strings, including named types of them,
+// are hashed straight from memory without reflecting on v first. Other
+// comparable types, such as structs, arrays and pointers, are hashed by
+// Hash.
+//
+// Values that Hash fails to hash, such as channels and complex128 numbers,
+// hash as zero. Hash returns zero for some values too, such as empty
+// arrays, so use Hash instead where failures must be told apart.
+func HashComparable[T comparable](v T) uint64 {
+	w, err := newWalker(nil)
+	if err != nil {
+		return 0
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.PkgPath() == "" || w.packageHandlers[t.PkgPath()] == nil {
+		k := t.Kind()
+		p := unsafe.Pointer(&v)
+		switch {
+		case k >= reflect.Bool && k <= reflect.Complex64:
+			return w.final(w.hashNumberAt(k, p))
+		case k == reflect.String:
+			w.h.Reset()
+			s := *(*string)(p)
+			_, _ = w.h.Write(*(*[]byte)(unsafe.Pointer(&s)))
+			return w.final(w.h.Sum64())
+		}
+	}
+
+	h, err := w.visit(reflect.ValueOf(v), visitOpts{Flags: visitFlagRoot})
+	if err != nil {
+		return 0
+	}
+	if err := w.finish(nil); err != nil {
+		return 0
+	}
+	return w.final(h)
+}
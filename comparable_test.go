@@ -0,0 +1,92 @@
+package hashstructure
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHashComparable(t *testing.T) {
+	type ID string
+	type Point struct {
+		X, Y int
+	}
+	type Key struct {
+		Name  string
+		Point Point
+		Valid bool
+	}
+
+	x := 1
+	cases := []struct {
+		Value interface{}
+		Hash  func() uint64
+	}{
+		{true, func() uint64 { return HashComparable(true) }},
+		{int(42), func() uint64 { return HashComparable(int(42)) }},
+		{int8(-1), func() uint64 { return HashComparable(int8(-1)) }},
+		{uint16(7), func() uint64 { return HashComparable(uint16(7)) }},
+		{int32(-5), func() uint64 { return HashComparable(int32(-5)) }},
+		{uint64(math.MaxUint64), func() uint64 { return HashComparable(uint64(math.MaxUint64)) }},
+		{uintptr(3), func() uint64 { return HashComparable(uintptr(3)) }},
+		{float32(1.5), func() uint64 { return HashComparable(float32(1.5)) }},
+		{math.Inf(-1), func() uint64 { return HashComparable(math.Inf(-1)) }},
+		{complex64(1 + 2i), func() uint64 { return HashComparable(complex64(1 + 2i)) }},
+		{"foo", func() uint64 { return HashComparable("foo") }},
+		{"", func() uint64 { return HashComparable("") }},
+		{ID("foo"), func() uint64 { return HashComparable(ID("foo")) }},
+		{time.Second, func() uint64 { return HashComparable(time.Second) }},
+		{Point{1, 2}, func() uint64 { return HashComparable(Point{1, 2}) }},
+		{Key{Name: "foo", Point: Point{3, 4}}, func() uint64 { return HashComparable(Key{Name: "foo", Point: Point{3, 4}}) }},
+		{[3]int{1, 2, 3}, func() uint64 { return HashComparable([3]int{1, 2, 3}) }},
+		{[2]string{"a", "b"}, func() uint64 { return HashComparable([2]string{"a", "b"}) }},
+		{&x, func() uint64 { return HashComparable(&x) }},
+	}
+
+	for _, tc := range cases {
+		expected, err := Hash(tc.Value, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Value, err)
+		}
+		if actual := tc.Hash(); actual != expected {
+			t.Fatalf("bad for %#v: %d != %d", tc.Value, actual, expected)
+		}
+	}
+
+	// Values Hash fails on hash as zero
+	if h := HashComparable(make(chan int)); h != 0 {
+		t.Fatalf("bad: %d", h)
+	}
+	if h := HashComparable(complex128(1 + 2i)); h != 0 {
+		t.Fatalf("bad: %d", h)
+	}
+}
+
+func TestHashComparable_registeredPackage(t *testing.T) {
+	RegisterPackage("time", func(v reflect.Value) (uint64, error) {
+		return 42, nil
+	})
+	defer RegisterPackage("time", nil)
+
+	if h := HashComparable(time.Second); h != 42 {
+		t.Fatalf("bad: %d", h)
+	}
+}
+
+func BenchmarkHashComparable(b *testing.B) {
+	b.Run("HashComparable", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			HashComparable(int64(i))
+		}
+	})
+	b.Run("Hash", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Hash(int64(i), nil); err != nil {
+				b.Fatalf("err: %s", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package hashstructure
+
+import (
+	"reflect"
+)
+
+// cycleKey identifies a pointer, map or slice that is being visited. The
+// type and length are included because a struct and its first field, or a
+// slice and a shorter slice of it, share the same pointer.
+type cycleKey struct {
+	ptr uintptr
+	len int
+	t   reflect.Type
+}
+
+// enterCycle records that v is being visited. It returns whether v was
+// tracked, in which case it must be passed to leaveCycle afterwards, and
+// whether v is already being visited, which makes it a cycle.
+func (w *walker) enterCycle(v reflect.Value) (key cycleKey, tracked, cycle bool) {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		if v.IsNil() {
+			return key, false, false
+		}
+		key = cycleKey{ptr: v.Pointer(), t: v.Type()}
+	case reflect.Slice:
+		if v.IsNil() {
+			return key, false, false
+		}
+		key = cycleKey{ptr: v.Pointer(), len: v.Len(), t: v.Type()}
+	default:
+		return key, false, false
+	}
+
+	if _, ok := w.visiting[key]; ok {
+		return key, false, true
+	}
+	if w.visiting == nil {
+		w.visiting = make(map[cycleKey]struct{})
+	}
+	w.visiting[key] = struct{}{}
+	return key, true, false
+}
+
+// leaveCycle records that the value of key is no longer being visited.
+func (w *walker) leaveCycle(key cycleKey) {
+	delete(w.visiting, key)
+}
+
+// hashCycle returns the sentinel hash used in place of a cyclic value of
+// type t.
+func (w *walker) hashCycle(t reflect.Type) uint64 {
+	name := t.String()
+	if w.canonical {
+		w.text = "cycle(" + name + ")"
+	}
+
+	w.h.Reset()
+	_, _ = w.h.Write([]byte(name))
+	return w.hashUpdateOrdered(cycleSentinel, w.h.Sum64())
+}
@@ -0,0 +1,115 @@
+package hashstructure
+
+import (
+	"testing"
+)
+
+func TestHash_cycles(t *testing.T) {
+	type Node struct {
+		Name     string
+		Children map[string]*Node
+		Links    []*Node
+	}
+
+	newMapCycle := func() *Node {
+		root := &Node{Name: "root", Children: map[string]*Node{}}
+		child := &Node{Name: "child", Children: map[string]*Node{}}
+		root.Children["child"] = child
+		child.Children["parent"] = root
+		return root
+	}
+
+	newSliceCycle := func() *Node {
+		root := &Node{Name: "root"}
+		child := &Node{Name: "child"}
+		root.Links = []*Node{child}
+		child.Links = []*Node{root}
+		return root
+	}
+
+	newSelfSlice := func() []interface{} {
+		s := make([]interface{}, 1)
+		s[0] = s
+		return s
+	}
+
+	newSelfMap := func() map[string]interface{} {
+		m := map[string]interface{}{"name": "root"}
+		m["self"] = m
+		return m
+	}
+
+	newMutualMaps := func() map[string]interface{} {
+		m := map[string]interface{}{"name": "m"}
+		n := map[string]interface{}{"name": "n", "m": m}
+		m["n"] = n
+		return m
+	}
+
+	cases := []struct {
+		Name string
+		New  func() interface{}
+	}{
+		{"map value", func() interface{} { return newMapCycle() }},
+		{"slice element", func() interface{} { return newSliceCycle() }},
+		{"self slice", func() interface{} { return newSelfSlice() }},
+		{"self map", func() interface{} { return newSelfMap() }},
+		{"mutual maps", func() interface{} { return newMutualMaps() }},
+		{"self map in slice", func() interface{} { return []interface{}{newSelfMap()} }},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			for _, opts := range []*HashOptions{nil, {Iterative: true}} {
+				one, err := Hash(tc.New(), opts)
+				if err != nil {
+					t.Fatalf("err: %s", err)
+				}
+				two, err := Hash(tc.New(), opts)
+				if err != nil {
+					t.Fatalf("err: %s", err)
+				}
+
+				// Zero is always wrong
+				if one == 0 {
+					t.Fatal("zero hash")
+				}
+
+				if one != two {
+					t.Fatalf("unstable hash of cyclic value: %d != %d", one, two)
+				}
+			}
+		})
+	}
+
+	// Cyclic values with different content still hash differently
+	other := newMapCycle()
+	other.Children["child"].Name = "other"
+	one, err := Hash(newMapCycle(), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(other, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one == two {
+		t.Fatalf("expected different hashes: %d", one)
+	}
+
+	// The same goes for maps that contain themselves
+	selfMap := newSelfMap()
+	otherMap := newSelfMap()
+	otherMap["name"] = "other"
+	one, err = Hash(selfMap, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err = Hash(otherMap, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one == two {
+		t.Fatalf("expected different hashes: %d", one)
+	}
+}
@@ -0,0 +1,72 @@
+package hashstructure
+
+import "reflect"
+
+// Equal reports whether a and b are equal by their hash values, which are
+// computed as with Hash and opts.
+//
+// Values of different dynamic types are never equal, even if the options,
+// such as NumericCoercion, would hash them the same, and Equal returns
+// false without hashing them. Likewise slices and maps of different
+// lengths aren't hashed, unless the options may hash them by something
+// else than their entries or leave some of the entries out.
+func Equal(a, b interface{}, opts *HashOptions) (bool, error) {
+	w, err := newWalker(opts)
+	if err != nil {
+		return false, err
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.IsValid() != vb.IsValid() || (va.IsValid() && va.Type() != vb.Type()) {
+		return false, nil
+	}
+	if w.lengthsDiffer(va, vb) {
+		return false, nil
+	}
+
+	ha, err := Hash(a, opts)
+	if err != nil {
+		return false, err
+	}
+	hb, err := Hash(b, opts)
+	if err != nil {
+		return false, err
+	}
+	return ha == hb, nil
+}
+
+// lengthsDiffer reports whether a and b, which have the same type, are
+// slices or maps of different lengths that can't hash the same.
+func (w *walker) lengthsDiffer(a, b reflect.Value) bool {
+	if !a.IsValid() {
+		return false
+	}
+
+	switch a.Kind() {
+	case reflect.Slice:
+	case reflect.Map:
+		// Keys that only differ in case are merged
+		if w.headerMaps {
+			return false
+		}
+	default:
+		return false
+	}
+
+	// Handlers may hash the value by anything, and so may the encodings
+	// of GobRoundTrip and PreferJSON
+	if w.preHash != nil || w.customHandled(a) || w.gobRoundTrip || w.preferJSON {
+		return false
+	}
+
+	// Elements and entries may be left out of the hash
+	if w.defaultPrototypes != nil || (a.Kind() == reflect.Map && w.treatEmptyAndAbsentEqual) {
+		return false
+	}
+	elem := a.Type().Elem()
+	if elem.Kind() == reflect.Interface || elem.Implements(selfIncludableType) ||
+		(w.pointerValueEquivalent && reflect.PtrTo(elem).Implements(selfIncludableType)) {
+		return false
+	}
+	return a.Len() != b.Len()
+}
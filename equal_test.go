@@ -0,0 +1,117 @@
+package hashstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	type Test struct {
+		Name string
+		Tags []string
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Equal    bool
+	}{
+		{1, 1, nil, true},
+		{1, 2, nil, false},
+		{Test{Name: "foo"}, Test{Name: "foo"}, nil, true},
+		{Test{Name: "foo", Tags: []string{"a"}}, Test{Name: "foo"}, nil, false},
+		{[]int{1, 2}, []int{1, 2}, nil, true},
+		{map[string]int{"a": 1}, map[string]int{"a": 1}, nil, true},
+		{nil, nil, nil, true},
+
+		// Different types are never equal
+		{1, "1", nil, false},
+		{int32(1), int64(1), &HashOptions{NumericCoercion: true}, false},
+		{[2]int{1, 2}, []int{1, 2}, nil, false},
+		{nil, 1, nil, false},
+		{(*int)(nil), nil, nil, false},
+
+		// Nor are slices and maps of different lengths
+		{[]int{1}, []int{1, 2}, nil, false},
+		{map[string]int{"a": 1}, map[string]int{"a": 1, "b": 2}, nil, false},
+
+		// Unless options may make them hash the same
+		{
+			map[string][]string{"Accept": {"a"}, "accept": {"b"}},
+			map[string][]string{"Accept": {"a", "b"}},
+			&HashOptions{HeaderMaps: true},
+			true,
+		},
+		{
+			[]int{1},
+			[]int{1, 2},
+			&HashOptions{PreHash: func(v reflect.Value) (uint64, bool, error) {
+				return 1, v.Kind() == reflect.Slice, nil
+			}},
+			true,
+		},
+		{
+			[]testSelfIncludable{{Value: "a"}},
+			[]testSelfIncludable{{Value: "a"}, {Value: "b", Exclude: true}},
+			nil,
+			true,
+		},
+		{
+			[]interface{}{"a"},
+			[]interface{}{"a", testSelfIncludable{Exclude: true}},
+			nil,
+			true,
+		},
+		{
+			[]int{1},
+			[]int{1, 0},
+			&HashOptions{DefaultPrototypes: map[reflect.Type]interface{}{reflect.TypeOf(0): 0}},
+			true,
+		},
+		{
+			map[string]string{"a": "x"},
+			map[string]string{"a": "x", "b": ""},
+			&HashOptions{TreatEmptyAndAbsentEqual: true},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		equal, err := Equal(tc.One, tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if equal != tc.Equal {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Equal, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestEqual_shortCircuit(t *testing.T) {
+	var probes int
+	opts := &HashOptions{OnCollisionProbe: func(a, b, result uint64) { probes++ }}
+
+	cases := [][2]interface{}{
+		{int(1), "1"},
+		{struct{ A string }{"foo"}, struct{ B string }{"foo"}},
+		{[]string{"a", "b"}, []string{"a"}},
+		{map[string]int{}, map[string]int{"a": 1}},
+	}
+	for _, tc := range cases {
+		equal, err := Equal(tc[0], tc[1], opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if equal || probes != 0 {
+			t.Fatalf("bad: %#v and %#v were hashed, equal: %t", tc[0], tc[1], equal)
+		}
+	}
+
+	// Values of the same shape are hashed
+	if _, err := Equal([]string{"a"}, []string{"b"}, opts); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if probes == 0 {
+		t.Fatal("bad: values weren't hashed")
+	}
+}
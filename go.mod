@@ -1 +1,5 @@
 module github.com/mitchellh/hashstructure
+
+go 1.18
+
+require golang.org/x/text v0.14.0
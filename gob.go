@@ -0,0 +1,37 @@
+package hashstructure
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// gobRoundTrip encodes v with encoding/gob and decodes it into a new value
+// of the same type, for GobRoundTrip.
+func gobRoundTrip(v reflect.Value) (reflect.Value, error) {
+	if !v.IsValid() {
+		return v, nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).EncodeValue(v); err != nil {
+		return reflect.Value{}, gobError("encoding", v.Type(), err)
+	}
+	dst := reflect.New(v.Type())
+	if err := gob.NewDecoder(&buf).DecodeValue(dst); err != nil {
+		return reflect.Value{}, gobError("decoding", v.Type(), err)
+	}
+	return dst.Elem(), nil
+}
+
+// gobError adds the type being round-tripped to a gob error, and how to
+// fix the common case of a type in an interface that isn't registered.
+func gobError(op string, t reflect.Type, err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "not registered for interface") {
+		msg += "; register it with gob.Register"
+	}
+	return fmt.Errorf("hashstructure: error %s %s with gob: %s", op, t, msg)
+}
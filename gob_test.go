@@ -0,0 +1,118 @@
+package hashstructure
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHash_gobRoundTrip(t *testing.T) {
+	type Inner struct {
+		Count int
+		Tags  []string
+	}
+	type Test struct {
+		Name   string
+		Inner  *Inner
+		Meta   map[string]int
+		Values []interface{}
+		secret string
+	}
+
+	gob.Register(testGobValue{})
+
+	opts := &HashOptions{GobRoundTrip: true}
+	cases := []interface{}{
+		Test{
+			Name:   "foo",
+			Inner:  &Inner{Count: 1, Tags: []string{"a", "b"}},
+			Meta:   map[string]int{"x": 1, "y": 2},
+			Values: []interface{}{"a", testGobValue{ID: 1}},
+			secret: "bar",
+		},
+		Test{Meta: map[string]int{}, Values: []interface{}{}},
+		&Test{Name: "foo"},
+		[]int{1, 2, 3},
+		"foo",
+	}
+
+	for _, v := range cases {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		decoded := reflect.New(reflect.TypeOf(v)).Elem()
+		if err := gob.NewDecoder(&buf).Decode(decoded.Addr().Interface()); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		one, err := Hash(v, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", v, err)
+		}
+		two, err := Hash(decoded.Interface(), opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", decoded.Interface(), err)
+		}
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", v)
+		}
+		if one != two {
+			t.Fatalf("bad, expected round-trip to hash the same:\n\n%#v\n\n%#v", v, decoded.Interface())
+		}
+	}
+
+	// What gob drops doesn't change the hash
+	one, err := Hash(Test{Name: "foo", secret: "a", Meta: map[string]int{}}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(Test{Name: "foo", secret: "b"}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatal("bad: unexported fields or empty maps changed the hash")
+	}
+
+	// But what it keeps does
+	three, err := Hash(Test{Name: "bar"}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one == three {
+		t.Fatal("bad: different values hash the same")
+	}
+}
+
+func TestHash_gobRoundTripError(t *testing.T) {
+	type Test struct {
+		Value interface{}
+	}
+
+	cases := []struct {
+		Value interface{}
+		Err   string
+	}{
+		{Test{Value: testGobUnregistered{}}, "register it with gob.Register"},
+		{Test{Value: make(chan int)}, "hashstructure: error encoding hashstructure.Test with gob"},
+		{struct{ Fn func() }{func() {}}, "hashstructure: error encoding struct { Fn func() } with gob"},
+	}
+
+	for _, tc := range cases {
+		_, err := Hash(tc.Value, &HashOptions{GobRoundTrip: true})
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("bad, expected error with %q for %#v, got: %v", tc.Err, tc.Value, err)
+		}
+	}
+}
+
+type testGobValue struct {
+	ID int
+}
+
+type testGobUnregistered struct {
+	ID int
+}
@@ -0,0 +1,50 @@
+package hashstructure
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"sync"
+)
+
+var (
+	hashersLock sync.RWMutex
+	hashers     = map[string]func() hash.Hash64{
+		"fnv":    fnv.New64,
+		"fnv1a":  fnv.New64a,
+		"sha256": func() hash.Hash64 { return truncatedHash{sha256.New()} },
+	}
+)
+
+// RegisterHasher registers a hash function under name for use with the
+// hash:"hasher:name" tag. The hash functions "fnv", "fnv1a" and "sha256"
+// are registered by default. Registering a name again replaces it.
+func RegisterHasher(name string, fn func() hash.Hash64) {
+	hashersLock.Lock()
+	defer hashersLock.Unlock()
+	hashers[name] = fn
+}
+
+// namedHasher returns a new hash function registered under name.
+func namedHasher(name string) (hash.Hash64, error) {
+	hashersLock.RLock()
+	fn, ok := hashers[name]
+	hashersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("hashstructure: no hasher registered as %q", name)
+	}
+	return fn(), nil
+}
+
+// truncatedHash adapts a hash.Hash to a hash.Hash64 by using the first 8
+// bytes of its sum.
+type truncatedHash struct {
+	hash.Hash
+}
+
+// Sum64 implements hash.Hash64 for truncatedHash
+func (t truncatedHash) Sum64() uint64 {
+	return binary.LittleEndian.Uint64(t.Sum(nil))
+}
@@ -0,0 +1,114 @@
+package hashstructure
+
+import (
+	"testing"
+)
+
+func TestHash_hasherTag(t *testing.T) {
+	type Test struct {
+		Name   string
+		Secret string `hash:"hasher:sha256"`
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			Test{Name: "foo", Secret: "bar"},
+			Test{Name: "foo", Secret: "bar"},
+			true,
+		},
+
+		{
+			Test{Name: "foo", Secret: "bar"},
+			Test{Name: "foo", Secret: "baz"},
+			false,
+		},
+
+		{
+			Test{Name: "foo", Secret: "bar"},
+			Test{Name: "bar", Secret: "bar"},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The field really is hashed with a different algorithm, while the
+	// rest of the struct still uses the default hasher.
+	sha, err := Hash(struct {
+		Name   string
+		Secret string `hash:"hasher:sha256"`
+	}{Name: "foo", Secret: "bar"}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	fnv, err := Hash(struct {
+		Name   string
+		Secret string
+	}{Name: "foo", Secret: "bar"}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if sha == fnv {
+		t.Fatalf("expected sha256 field to change the hash: %d", sha)
+	}
+}
+
+func TestHash_hasherTagRestored(t *testing.T) {
+	type Test struct {
+		Secret string `hash:"hasher:sha256"`
+		Name   string
+	}
+
+	type Plain struct {
+		Name string
+	}
+
+	// Hashing a sha256 field must not leave the hasher swapped
+	opts := &HashOptions{}
+	if _, err := Hash(Test{Secret: "bar", Name: "foo"}, opts); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	one, err := Hash(Plain{Name: "foo"}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(Plain{Name: "foo"}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatalf("hasher not restored: %d != %d", one, two)
+	}
+}
+
+func TestHash_hasherTagUnknown(t *testing.T) {
+	type Test struct {
+		Name string `hash:"hasher:unknown"`
+	}
+
+	if _, err := Hash(Test{Name: "foo"}, nil); err == nil {
+		t.Fatal("expected error for unknown hasher")
+	}
+}
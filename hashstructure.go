@@ -1,23 +1,143 @@
 package hashstructure
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"hash"
 	"hash/fnv"
 	"math"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"unsafe"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // ErrNotStringer is returned when there's an error with hash:"string"
 type ErrNotStringer struct {
 	Field string
+
+	// Tag is the TagName of the tag, if it isn't "hash".
+	Tag string
 }
 
 // Error implements error for ErrNotStringer
 func (ens *ErrNotStringer) Error() string {
-	return fmt.Sprintf("hashstructure: %s has hash:\"string\" set, but does not implement fmt.Stringer", ens.Field)
+	return fmt.Sprintf("hashstructure: %s has %s:\"string\" set, but does not implement fmt.Stringer or fmt.GoStringer",
+		ens.Field, tagName(ens.Tag))
+}
+
+// ErrInvalidMethod is returned when there's an error with hash:"method:..."
+type ErrInvalidMethod struct {
+	Field  string
+	Method string
+
+	// Tag is the TagName of the tag, if it isn't "hash".
+	Tag string
+}
+
+// Error implements error for ErrInvalidMethod
+func (eim *ErrInvalidMethod) Error() string {
+	return fmt.Sprintf("hashstructure: %s has %s:\"method:%s\" set, but %s is not a method "+
+		"without arguments returning a value and optionally an error", eim.Field, tagName(eim.Tag), eim.Method, eim.Method)
+}
+
+// tagName returns the name of the tag in errors, which is "hash" if no
+// other TagName is given.
+func tagName(tag string) string {
+	if tag == "" {
+		return "hash"
+	}
+	return tag
+}
+
+// ErrPanic is returned when a method called while hashing, such as String
+// for hash:"string", panics. Field is the struct field being hashed, if
+// any, and Value is the value the method panicked with.
+type ErrPanic struct {
+	Field  string
+	Method string
+	Value  interface{}
+}
+
+// Error implements error for ErrPanic
+func (ep *ErrPanic) Error() string {
+	if ep.Field == "" {
+		return fmt.Sprintf("hashstructure: %s panicked: %v", ep.Method, ep.Value)
+	}
+	return fmt.Sprintf("hashstructure: %s panicked while hashing %s: %v", ep.Method, ep.Field, ep.Value)
+}
+
+// ErrFloat is returned when HashOptions.RejectFloats is set and a float of
+// the given kind is found at Path, such as "Items[0].Price". Path is empty
+// for the value being hashed itself.
+type ErrFloat struct {
+	Path string
+	Kind reflect.Kind
+}
+
+// Error implements error for ErrFloat
+func (ef *ErrFloat) Error() string {
+	if ef.Path == "" {
+		return fmt.Sprintf("hashstructure: %s value rejected by RejectFloats", ef.Kind)
+	}
+	return fmt.Sprintf("hashstructure: %s value at %s rejected by RejectFloats", ef.Kind, ef.Path)
+}
+
+// ErrUnroundable is returned when HashOptions.RoundFloatsToInt is set and
+// the float Value at Path, such as NaN, an infinity or a float beyond the
+// range of int64, has no nearest int64. Path is empty for the value being
+// hashed itself.
+type ErrUnroundable struct {
+	Path  string
+	Value float64
+}
+
+// Error implements error for ErrUnroundable
+func (eu *ErrUnroundable) Error() string {
+	if eu.Path == "" {
+		return fmt.Sprintf("hashstructure: %v cannot be rounded to an int64 by RoundFloatsToInt", eu.Value)
+	}
+	return fmt.Sprintf("hashstructure: %v at %s cannot be rounded to an int64 by RoundFloatsToInt", eu.Value, eu.Path)
+}
+
+// ErrField is an error hashing the struct field at Path, such as
+// "Items[0].Name".
+type ErrField struct {
+	Path string
+	Err  error
+}
+
+// Error implements error for ErrField
+func (ef *ErrField) Error() string {
+	return fmt.Sprintf("%s: %s", ef.Path, ef.Err)
+}
+
+// Unwrap returns the underlying error
+func (ef *ErrField) Unwrap() error {
+	return ef.Err
+}
+
+// ErrFields is returned when HashOptions.CollectErrors is set and one or
+// more fields couldn't be hashed.
+type ErrFields struct {
+	Errors []*ErrField
+}
+
+// Error implements error for ErrFields
+func (efs *ErrFields) Error() string {
+	msgs := make([]string, len(efs.Errors))
+	for i, err := range efs.Errors {
+		msgs[i] = "\t* " + err.Error()
+	}
+	return fmt.Sprintf("hashstructure: %d field(s) could not be hashed:\n%s", len(efs.Errors), strings.Join(msgs, "\n"))
 }
 
 // HashOptions are options that are available for hashing.
@@ -33,6 +153,495 @@ type HashOptions struct {
 	// ZeroNil is flag determining if nil pointer should be treated equal
 	// to a zero value of pointed type. By default this is false.
 	ZeroNil bool
+
+	// OnlyFields, if set, is the list of top-level struct field names that
+	// are included in the hash. All other fields of the top-level struct
+	// are ignored. Hashing returns an error if a name doesn't match a field
+	// of the top-level struct or if the top-level value isn't a struct.
+	// With FlattenEmbedded the names can also be those of fields promoted
+	// from embedded structs, and an embedded struct that isn't listed
+	// itself hashes only its listed fields. With UseGetters the names are those of getters instead, and names
+	// that don't match a getter are ignored.
+	OnlyFields []string
+
+	// NumericCoercion, if true, hashes all integer values and all floats
+	// holding an integral value with the same 64-bit integer encoding, so
+	// that int(1), int32(1) and float64(1.0) hash equal. This is useful for
+	// values decoded from JSON, where all numbers are float64.
+	NumericCoercion bool
+
+	// SkipNilPointers, if true, omits struct fields holding a nil pointer
+	// from the hash, as if the field didn't exist. This takes precedence
+	// over ZeroNil for struct fields. By default this is false.
+	SkipNilPointers bool
+
+	// SkipNilInterfaces, if true, omits struct fields of interface type
+	// holding nil from the hash, as if the field didn't exist, instead of
+	// hashing them like a zero int. Fields holding a nil pointer in a
+	// non-nil interface are kept. IncludeInterfaceType never folds a type
+	// for nil interfaces, so it doesn't change which fields are skipped.
+	// By default this is false.
+	SkipNilInterfaces bool
+
+	// InterfaceHandlers are used to hash values implementing an interface
+	// instead of reflecting into the concrete type. The first handler whose
+	// interface is implemented by a value is used.
+	InterfaceHandlers []InterfaceHandler
+
+	// CollectErrors, if true, keeps hashing when a struct field can't be
+	// hashed. The field is skipped and its error is collected. Hash then
+	// returns the hash of the remaining fields along with an *ErrFields
+	// listing every failed field.
+	CollectErrors bool
+
+	// ProtoMessages, if true, hashes only the logical fields of protobuf
+	// messages, skipping the bookkeeping fields generated by protoc such as
+	// XXX_unrecognized and XXX_sizecache. Messages are detected by their
+	// method set, so this doesn't depend on a protobuf package. The
+	// unexported state, sizeCache and unknownFields fields of APIv2
+	// messages are skipped too, even with IncludeUnexported.
+	ProtoMessages bool
+
+	// NormalizeTime, if true, hashes time.Time values by the instant they
+	// represent, wherever they appear, including map keys. Times in
+	// different locations or with or without a monotonic clock reading
+	// hash equal if they are the same instant, like with time.Time.Equal.
+	// By default time.Time is hashed like any other struct, and as it has
+	// no exported fields all times hash equal.
+	NormalizeTime bool
+
+	// Domain, if set, is folded into the final hash value, so identical
+	// values hashed for different purposes (such as cache keys and dedup
+	// keys) have different hash values.
+	Domain string
+
+	// Version, if set, is folded into the final hash value after Domain,
+	// so bumping it changes every hash value at once, such as to
+	// invalidate cached hashes after a change of the options.
+	Version uint64
+
+	// Iterative, if true, hashes nested slices and arrays with an explicit
+	// stack instead of recursion, so very deeply nested values don't
+	// exhaust the goroutine stack. Other kinds, such as structs and maps,
+	// are still visited recursively. The hash value is the same either way.
+	Iterative bool
+
+	// FlattenEmbedded, if true, hashes the fields of embedded structs as if
+	// they were declared in the embedding struct, so a struct embedding
+	// Base hashes like one declaring the fields of Base directly. This
+	// applies at any depth. Like with Go's promoted fields, a field hides
+	// the fields of the same name nested deeper, and fields of the same
+	// name at the same depth hide each other. A nil embedded pointer is
+	// skipped with SkipNilPointers, flattened as the zero value with
+	// ZeroNil, and otherwise hashed as nil.
+	FlattenEmbedded bool
+
+	// TimeZoneSensitive, if true, hashes time.Time values by their instant
+	// and their location, so the same instant in different time zones
+	// hashes differently. The zone name and offset are both hashed. This
+	// cannot be combined with NormalizeTime. By default this is false.
+	TimeZoneSensitive bool
+
+	// UnicodeNormalization is the Unicode normalization form applied to
+	// strings before hashing them, so canonically equivalent strings such
+	// as precomposed and decomposed characters hash equal. By default
+	// strings are hashed as is.
+	UnicodeNormalization NormalizationForm
+
+	// OrderedFields, if true, combines the fields of structs in the order
+	// of their sorted hashes instead of XORing them, so fields with equal
+	// hashes don't cancel out. Struct map keys are hashed the same way. By
+	// default this is false.
+	OrderedFields bool
+
+	// BulkNumbers, if true, hashes slices and arrays of integers and floats
+	// that aren't sets by writing all their elements to the Hasher at
+	// once, followed by their length, instead of hashing and combining each
+	// element. This is much faster for large numeric slices, but gives
+	// different hash values. Options that change how elements are hashed,
+	// such as NumericCoercion, turn it off for the affected slices. By
+	// default this is false.
+	BulkNumbers bool
+
+	// IncludeInterfaceType, if true, folds the concrete type of values held
+	// in interfaces into their hash, so that for example the elements of
+	// []interface{}{1, "1"} hash by type as well as by value. By default
+	// this is false.
+	IncludeInterfaceType bool
+
+	// ByteOrder is the byte order numbers are serialized in before they
+	// are hashed, so the hash can match an external spec. By default this
+	// is binary.LittleEndian.
+	ByteOrder binary.ByteOrder
+
+	// FieldFilter, if set, is called for each struct field to check
+	// whether it should be included in the hash. This centralizes rules
+	// that would otherwise need Includable on every type. A field is only
+	// included if both FieldFilter and Includable include it.
+	FieldFilter func(structType reflect.Type, field reflect.StructField, value reflect.Value) (bool, error)
+
+	// DefaultPrototypes are the default values of types. Struct fields,
+	// slice and array elements and map entries whose value is
+	// reflect.DeepEqual to the prototype of its type are omitted from the
+	// hash, like SelfIncludable values that exclude themselves. This is
+	// useful for types whose default isn't their zero value.
+	DefaultPrototypes map[reflect.Type]interface{}
+
+	// UseGoStringer, if true, hashes values implementing fmt.GoStringer as
+	// the string returned by GoString instead of walking them. Note that
+	// fmt.Stringer is only used with the "string" tag, and that
+	// encoding.TextMarshaler isn't used. NormalizeTime and
+	// TimeZoneSensitive still apply to time.Time, which implements
+	// GoStringer. By default this is false.
+	UseGoStringer bool
+
+	// ErrorsAsString, if true, hashes values implementing error as the
+	// string returned by Error instead of walking them, so errors with the
+	// same message hash the same. A nil error, such as a nil element of an
+	// []error, hashes as a sentinel distinct from any message, including
+	// the empty one. An error holding a nil pointer is walked like any nil
+	// pointer. By default this is false.
+	ErrorsAsString bool
+
+	// CommonTypeCanonicalization, if true, hashes some common types that
+	// have a canonical text form as that text, so they hash like the
+	// string of it:
+	//
+	//   - UUIDs, which are 16-byte arrays implementing
+	//     encoding.TextMarshaler, such as github.com/google/uuid.UUID, as
+	//     the text of MarshalText.
+	//   - net/mail.Address as the text of its String method.
+	//
+	// The types are detected by their shape and name rather than imported.
+	// By default these are hashed like any other value.
+	CommonTypeCanonicalization bool
+
+	// StdlibCanonicalization, if true, hashes some standard library types
+	// by what they represent rather than by their fields, most of which
+	// are unexported:
+	//
+	//   - The Null types of database/sql, such as sql.NullString, hash
+	//     like their value if Valid is set. If it isn't, all values of a
+	//     Null type hash the same, whatever the value is.
+	//   - time.Time hashes by the instant it represents, like with
+	//     NormalizeTime. NormalizeTime and TimeZoneSensitive take
+	//     precedence over it.
+	//   - time.Duration hashes like its String, such as "1m30s".
+	//   - time.Location hashes like its name.
+	//
+	// By default these are hashed like any other value.
+	StdlibCanonicalization bool
+
+	// IncludeMethodSet, if true, includes the exported methods of the type
+	// of a struct in its hash, by their names and signatures. The methods
+	// of the pointer type are included too, since they decide the
+	// interfaces it implements. Structs of types with the same name and
+	// fields but different methods then have different hash values, while
+	// types without methods hash as they do without it. By default methods
+	// aren't hashed.
+	IncludeMethodSet bool
+
+	// GobRoundTrip, if true, hashes v as it is after encoding it with
+	// encoding/gob and decoding it into a new value of the same type, so v
+	// hashes the same before and after it is stored with gob. Like gob,
+	// this leaves out unexported fields, follows pointers and doesn't tell
+	// nil from empty slices and maps. Types stored in interfaces must be
+	// registered with gob.Register. The encoded bytes aren't hashed
+	// themselves, since gob writes maps in iteration order. By default v
+	// is hashed as it is.
+	GobRoundTrip bool
+
+	// PanicOnNonDeterministic, if true, panics when a value is found that
+	// can't be hashed deterministically and no handler such as PreHash,
+	// InterfaceHandlers or RegisterPackage hashes it: a channel, unless
+	// DrainChannels is set, a func, an unsafe.Pointer or a sync.Map. The
+	// panic message names the path of the value. This is meant to catch
+	// such values during development. By default channels, funcs and
+	// unsafe pointers make hashing fail with an error, and a sync.Map
+	// hashes like any struct without exported fields.
+	PanicOnNonDeterministic bool
+
+	// RejectFloats, if true, makes hashing fail with an *ErrFloat when a
+	// float32 or float64 value is found, unless a handler such as PreHash
+	// hashes it first. This guards hash values that must be reproducible
+	// across languages against floats, whose representation differs. By
+	// default floats are hashed by their bits.
+	RejectFloats bool
+
+	// RoundFloatsToInt, if true, rounds float32 and float64 values to the
+	// nearest integer, with halves rounded away from zero, and hashes them
+	// like that int64. This is meant for floats that count things, so that
+	// drift such as 2.9999999 hashes like 3. Hashing fails with an
+	// *ErrUnroundable for NaN, infinities and floats beyond the range of
+	// int64. RejectFloats takes precedence over it. By default floats are
+	// hashed by their bits.
+	RoundFloatsToInt bool
+
+	// JSONOmitEmpty, if true, omits struct fields tagged with the json
+	// omitempty option from the hash when encoding/json would omit them:
+	// false, 0, a nil pointer or interface, or an empty array, slice, map
+	// or string. Structs are never empty. By default this is false.
+	JSONOmitEmpty bool
+
+	// MapCanonicalJSON, if true, hashes maps with string keys as the string
+	// of their JSON encoding with sorted keys and without HTML escaping,
+	// which is reproducible outside of Go. Nested values are part of the
+	// JSON, so the other options don't apply to them, and neither does
+	// IncludableMap. By default this is false.
+	MapCanonicalJSON bool
+
+	// PreferJSON, if true, hashes the value being hashed as the string of
+	// its canonical JSON encoding: it is encoded with encoding/json, and
+	// the objects in it are then encoded with sorted keys and numbers as
+	// they were encoded, without HTML escaping. Values with the same JSON
+	// encoding, such as structs whose fields are declared in a different
+	// order, then hash the same, and the hash can be reproduced outside
+	// of Go. As nothing is walked, the other options and the tags don't
+	// apply, except Domain and Version. Values encoding/json can't encode
+	// make hashing fail. By default this is false.
+	PreferJSON bool
+
+	// DrainChannels, if true, hashes channels like a slice of the elements
+	// currently buffered in them. The elements are received and then sent
+	// again in the same order, so the channel holds the same elements
+	// afterwards.
+	//
+	// This is only safe for channels that no other goroutine uses while
+	// they are hashed: a concurrent send or receive changes the hashed
+	// elements, or leaves the channel with elements missing or reordered.
+	// Directional channels can't be drained and closed channels holding
+	// elements can't be restored, so hashing them is an error. By default
+	// channels can't be hashed.
+	DrainChannels bool
+
+	// HeaderMaps, if true, hashes maps with string keys and values that are
+	// slices of strings, such as http.Header, the way HTTP headers compare:
+	// keys are case-insensitive, and the values of a key are a set. Keys
+	// that only differ in case are merged. This also applies to other maps
+	// of the same shape, such as url.Values. By default this is false.
+	HeaderMaps bool
+
+	// UnsafeFastPath, if true, reads bools and numbers directly from
+	// memory with package unsafe instead of through reflect, where their
+	// address is known, such as for the fields of a struct passed by
+	// pointer. The hash is the same either way. By default this is false.
+	UnsafeFastPath bool
+
+	// NonZeroEmptyStructs, if true, folds the full string of the type of a
+	// struct, such as "pkg.Empty", into its hash if none of its fields are
+	// hashed, because it has no fields or they are all skipped. Anonymous
+	// structs fold "struct {}", so skipped fields count as if they didn't
+	// exist. Otherwise such structs only hash their name, so the same name
+	// in different packages collides, and anonymous structs hash like the
+	// empty string. By default this is false.
+	NonZeroEmptyStructs bool
+
+	// PointerValueEquivalent, if true, hashes a pointer to a value the same
+	// as the value itself everywhere. Pointers are always dereferenced, but
+	// methods with pointer receivers, such as for InterfaceHandlers,
+	// UseGoStringer and SelfIncludable, are otherwise only found through a
+	// pointer, and IncludeInterfaceType and DefaultPrototypes otherwise
+	// tell *T and T apart. Nil pointers are still hashed as set by ZeroNil.
+	// By default this is false.
+	PointerValueEquivalent bool
+
+	// MapSets, if true, hashes maps whose values are empty structs, such
+	// as map[string]struct{}, as sets of their keys, like slices tagged
+	// hash:"set". This hashes their keys with the same combiner as sets
+	// instead of XORing each key with the hash of an empty value. Maps
+	// that implement OrderedMapper are hashed as usual. By default this is
+	// false.
+	MapSets bool
+
+	// MaxMapEntries, if positive, bounds the cost of hashing large maps by
+	// hashing at most this many of their entries, along with the number of
+	// entries. The entries are chosen by the hashes of their keys, or by
+	// HashKeyOrder for an OrderedMapper, so the same map always hashes the
+	// same. Changes to the other entries aren't detected though, so maps
+	// of the same size that only differ in those entries collide. Every
+	// key is still hashed to choose the entries. By default all entries are
+	// hashed.
+	MaxMapEntries int
+
+	// IncludeUnexported, if true, hashes unexported struct fields like
+	// exported ones, except for blank fields. Their values are read with
+	// package unsafe, so tags, methods and Stringers work on them as well.
+	// By default unexported fields are ignored.
+	IncludeUnexported bool
+
+	// AnonymousStructs, if true, hashes the type of anonymous structs as
+	// their structure, such as "struct { A int }", instead of their empty
+	// name. Otherwise anonymous structs with fields of the same names but
+	// different types, such as struct{ A int } and struct{ A uint }, can
+	// collide. StructNameFunc takes precedence over this. By default this
+	// is false.
+	AnonymousStructs bool
+
+	// UseGetters, if true, hashes structs by the results of their getters
+	// instead of their fields. A getter is an exported method that takes
+	// no arguments and returns a single value, and whose name is accepted
+	// by GetterFilter. Each result is hashed with the name of its method
+	// the same way as a field. Getters are called every time the struct is
+	// hashed, so they must not have side effects. By default structs are
+	// hashed by their fields.
+	UseGetters bool
+
+	// GetterFilter, if set, returns whether the method of the given name
+	// is a getter with UseGetters. By default methods whose names start
+	// with "Get" are getters.
+	GetterFilter func(name string) bool
+
+	// FieldNameFromTag, if set, is the name of a struct tag, such as
+	// "json", whose name is hashed as the name of a field instead of its
+	// Go name. The name is the part of the tag before the first comma.
+	// Fields without a name in the tag, or with the name "-", keep their Go
+	// name. By default field names aren't taken from a tag.
+	FieldNameFromTag string
+
+	// FieldAliases, if set, maps the names of struct fields to the names
+	// hashed for them instead, such as to keep the hash of a renamed field
+	// by mapping its new name to the old one. The names are looked up
+	// after FieldNameFromTag, so with it they are the names from the tag.
+	// The names of getters with UseGetters are looked up too. FieldSalting
+	// still salts the values by their Go names, so a renamed field only
+	// keeps its hash without it. By default field names aren't aliased.
+	FieldAliases map[string]string
+
+	// NormalizeFieldNames, if set, is applied to the name hashed for each
+	// struct field, after FieldNameFromTag and FieldAliases. This lets names that only
+	// differ in spelling, such as "user_id" and "userId", hash equal.
+	NormalizeFieldNames func(string) string
+
+	// FieldNameHasher, if set, returns the hash of the name of each struct
+	// field, after FieldNameFromTag and NormalizeFieldNames, instead of
+	// hashing it like a string with Hasher. This keeps hash values stable
+	// across changes to Hasher or to how strings are hashed, as far as
+	// field names are concerned. The values of fields are hashed as usual.
+	// By default field names are hashed like strings.
+	FieldNameHasher func(name string) uint64
+
+	// FieldSalting, if true, folds a salt into the hash of the value of
+	// each struct field before it is combined with the field name. The
+	// salt is derived from a fixed seed and the Go name of the field, even
+	// if another name is hashed because of FieldNameFromTag or
+	// FieldNameHasher. Fields hashed under the same name are then still
+	// told apart: their values can't cancel out, and a value moved to
+	// another field changes the hash. By default this is false.
+	FieldSalting bool
+
+	// StructNameFunc, if set, returns the name that is hashed as the type
+	// of a struct. By default this is the name of the type without its
+	// package, so types of the same name in different packages can collide.
+	// Returning the package path as well tells them apart.
+	StructNameFunc func(reflect.Type) string
+
+	// FieldOrderFunc, if set, is called with each struct type to get the
+	// order its fields are combined in. The fields are then hashed in that
+	// order with an ordered combiner instead of being XORed, followed by
+	// any fields that aren't listed in declaration order. This pins the
+	// hash of a type to a schema rather than to its declaration. If it
+	// returns nil for a type, its fields are combined as usual. This takes
+	// precedence over OrderedFields.
+	FieldOrderFunc func(reflect.Type) []string
+
+	// CombineStats, if set, is incremented with the number of combine
+	// operations of each hash. This can be used to estimate the risk of
+	// collisions for a workload. It must not be shared by concurrent
+	// hashes.
+	CombineStats *CombineStats
+
+	// OnCollisionProbe, if set, is called with the inputs and result of
+	// every ordered combine, such as to measure the distribution of the
+	// combiner. It must not change the state of the hashed values.
+	OnCollisionProbe func(a, b, result uint64)
+
+	// OrderedCombiner, if set, replaces the ordered combiner, which folds
+	// the elements of slices and arrays, the keys and values of map
+	// entries, struct fields and other values whose order matters. It is
+	// called with the Hasher, which it may use if it resets it first, but
+	// it doesn't have to, such as to mix a and b with multiplications
+	// instead. It must be deterministic and should depend on the order of
+	// a and b. By default a and b are written to the Hasher.
+	OrderedCombiner func(h hash.Hash64, a, b uint64) uint64
+
+	// PreHash, if set, is called with every value before it is hashed,
+	// including values held in interfaces or pointers before they are
+	// dereferenced. If it returns true, the returned hash is used for the
+	// value and the value isn't walked any further. Otherwise the value is
+	// hashed as usual and PreHash is called again for its elements.
+	//
+	// PreHash is responsible for hashing everything it handles, such as the
+	// elements of a value. It may call Hash to do so, but not with these
+	// options, since the Hasher is in use by the walk.
+	PreHash func(v reflect.Value) (uint64, bool, error)
+
+	// MapValueTransform, if set, is called with every value of a map, as
+	// it is stored in the map, and its result is hashed instead. This
+	// normalizes values that vary cosmetically, such as by trimming
+	// strings. The keys are hashed as they are, and HashInclude is called
+	// with the stored value. It doesn't apply to maps hashed as a whole,
+	// with MapCanonicalJSON or HeaderMaps. By default map values are hashed
+	// as they are.
+	MapValueTransform func(reflect.Value) (reflect.Value, error)
+
+	// TreatEmptyAndAbsentEqual, if true, skips map entries whose value is
+	// empty, so a key with an empty value hashes as if it were absent:
+	// {"a": 0} hashes like {}, but not like {"a": 1}. Values are empty by
+	// the rules of the json omitempty option, also when held in
+	// interfaces: false, 0, a nil pointer or interface, or an empty array,
+	// map, slice or string, whether nil or not. As nil and empty maps
+	// already hash the same, this makes a map hash like any map with the
+	// same non-empty entries. The entries are skipped before
+	// MaxMapEntries counts them and MapValueTransform is called. It
+	// doesn't apply to maps hashed as a whole, with MapSets, HeaderMaps or
+	// MapCanonicalJSON. By default every entry is hashed.
+	TreatEmptyAndAbsentEqual bool
+}
+
+// NormalizationForm is a Unicode normalization form for
+// HashOptions.UnicodeNormalization.
+type NormalizationForm int
+
+const (
+	// NormalizeNone hashes strings as is.
+	NormalizeNone NormalizationForm = iota
+
+	// NormalizeNFC hashes strings in canonical composition form.
+	NormalizeNFC
+
+	// NormalizeNFD hashes strings in canonical decomposition form.
+	NormalizeNFD
+
+	// NormalizeNFKC hashes strings in compatibility composition form.
+	NormalizeNFKC
+
+	// NormalizeNFKD hashes strings in compatibility decomposition form.
+	NormalizeNFKD
+)
+
+// CombineStats counts the combine operations of hashing, for
+// HashOptions.CombineStats.
+type CombineStats struct {
+	// Ordered is the number of ordered combines, such as of slice
+	// elements or of a field name with its value.
+	Ordered uint64
+
+	// Unordered is the number of XOR combines, such as of struct fields and
+	// map entries.
+	Unordered uint64
+
+	// Set is the number of combines of set elements.
+	Set uint64
+}
+
+// InterfaceHandler hashes all values whose type implements Iface with Fn.
+type InterfaceHandler struct {
+	// Iface is the interface type, such as
+	// reflect.TypeOf((*fs.FileInfo)(nil)).Elem(). It must be an interface.
+	Iface reflect.Type
+
+	// Fn returns the hash of v, which is never a nil pointer.
+	Fn func(v reflect.Value) (uint64, error)
 }
 
 // Hash returns the hash value of an arbitrary value.
@@ -45,11 +654,48 @@ type HashOptions struct {
 // Notes on the value:
 //
 //   * Unexported fields on structs are ignored and do not affect the
-//     hash value.
+//     hash value, unless IncludeUnexported is set.
 //
 //   * Adding an exported field to a struct with the zero value will change
 //     the hash value.
 //
+//   * A nil slice hashes the same as an empty slice, and a nil map the same
+//     as an empty map, since both are hashed by their elements.
+//
+//   * An array hashes the same as a slice with the same elements. Each
+//     element is part of the hash, including trailing zero values, so
+//     [2]int{1, 0} and [3]int{1, 0, 0} have different hash values.
+//
+//   * A reflect.Type is hashed as the string of its String method, such as
+//     "[]int" or "time.Time".
+//
+//   * The name of a struct type is part of the hash value. For instantiated
+//     generic types the name includes the type arguments, so Box[int]{}
+//     and Box[string]{} have different hash values.
+//
+// Each value is hashed by the first of these that applies to it:
+//
+//   1. PreHash, if it handles the value.
+//
+//   2. The first of the InterfaceHandlers whose interface the value
+//      implements, then the handler registered with RegisterPackage for
+//      the package of its type. The handlers are checked for a pointer
+//      before the value it points to.
+//
+//   3. NormalizeTime or TimeZoneSensitive for time.Time values, the name
+//      of the type for reflect.Type values, CommonTypeCanonicalization,
+//      then StdlibCanonicalization.
+//
+//   4. ErrorsAsString for values implementing error, then UseGoStringer
+//      for values implementing fmt.GoStringer.
+//
+//   5. Reflection, including MapCanonicalJSON, UseGetters and the other
+//      options that change how a kind of value is walked.
+//
+// The tags and the Includable and SelfIncludable interfaces of a struct
+// field apply before any of these, since they decide whether and as what
+// the field is hashed at all.
+//
 // For structs, the hashing can be controlled using tags. For example:
 //
 //    struct {
@@ -60,41 +706,321 @@ type HashOptions struct {
 // The available tag values are:
 //
 //   * "ignore" or "-" - The field will be ignored and not affect the hash code.
+//                       The struct hashes exactly like the same struct
+//                       without the field, unless AnonymousStructs names
+//                       an anonymous struct by its definition.
 //
 //   * "set" - The field will be treated as a set, where ordering doesn't
-//             affect the hash code. This only works for slices.
+//             affect the hash code. This only works for slices. Duplicate
+//             elements are counted, so [a, a] and [a] hash differently.
+//             Pointer elements are compared by what they point to, so
+//             two pointers to equal values count as two equal elements.
+//
+//   * "revset" - The field will be hashed the same as its reversal, so
+//                [1, 2, 3] and [3, 2, 1] hash equal, but [2, 1, 3] doesn't.
+//                This only works for slices.
 //
 //   * "string" - The field will be hashed as a string, only works when the
-//                field implements fmt.Stringer
+//                field implements fmt.Stringer or fmt.GoStringer. String
+//                takes precedence over GoString.
+//
+//   * "method:Name" - The field will be hashed as the result of calling the
+//                     method Name of the struct instead. The method must not
+//                     take arguments and must return a value and optionally
+//                     an error.
+//
+//   * "call" - The field holds a function that is called to get the value
+//              that is hashed in its place. The function must not take
+//              arguments and must return a value and optionally an error.
+//
+//   * "hasher:Name" - The value of the field will be hashed with the hash
+//                     function registered with RegisterHasher under Name,
+//                     such as "sha256".
+//
+//   * "lower", "upper" or "trim" - Strings are lowercased, uppercased or
+//                                  trimmed before they are hashed. For
+//                                  slices and arrays of strings this
+//                                  applies to every element. These can
+//                                  follow another value, as in
+//                                  hash:"set,lower" for a case-insensitive
+//                                  set.
+//
+//   * "oneof=Field" - The field is one of the fields of a tagged union,
+//                     which is only hashed while it is active. It is
+//                     active while the sibling Field holds the name of
+//                     the field, or with "oneof=Field:a|b" one of the
+//                     listed values, compared to the string of Field
+//                     ignoring case. Otherwise it is hashed as if it
+//                     were removed, like an ignored field.
+//
+//   * "lenonly" - Only the length of the field is hashed, not its contents.
+//                 This only works for arrays, slices, maps and strings.
+//
+//   * "bucket" - The field is part of the bucket hash of HashBucketed. This
+//                doesn't affect Hash.
 //
 func Hash(v interface{}, opts *HashOptions) (uint64, error) {
+	w, err := newWalker(opts)
+	if err != nil {
+		return 0, err
+	}
+	h, err := w.visit(reflect.ValueOf(v), visitOpts{Flags: visitFlagRoot})
+	if err != nil {
+		return h, err
+	}
+	return w.final(h), w.finish(nil)
+}
+
+// Verify hashes v n times and returns an error if the hash values differ.
+// This catches non-determinism in the hashed values, such as custom
+// handlers or methods that don't always return the same result.
+//
+// The options are the same as for Hash.
+func Verify(v interface{}, opts *HashOptions, n int) error {
+	var first uint64
+	for i := 0; i < n; i++ {
+		h, err := Hash(v, opts)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			first = h
+		} else if h != first {
+			return fmt.Errorf("hashstructure: non-deterministic hash: attempt %d returned %d, attempt 0 returned %d", i, h, first)
+		}
+	}
+	return nil
+}
+
+// newWalker fills in the default values of opts and creates a walker
+// configured by it.
+func newWalker(opts *HashOptions) (*walker, error) {
 	// Create default options
 	if opts == nil {
 		opts = &HashOptions{}
 	}
+	if opts.NormalizeTime && opts.TimeZoneSensitive {
+		return nil, fmt.Errorf("hashstructure: NormalizeTime and TimeZoneSensitive cannot both be set")
+	}
 	if opts.Hasher == nil {
 		opts.Hasher = fnv.New64()
 	}
 	if opts.TagName == "" {
 		opts.TagName = "hash"
 	}
+	if opts.ByteOrder == nil {
+		opts.ByteOrder = binary.LittleEndian
+	}
 
 	// Reset the hash
 	opts.Hasher.Reset()
 
-	// Create our walker and walk the structure
+	// Create our walker
 	w := &walker{
-		h:       opts.Hasher,
-		tag:     opts.TagName,
-		zeronil: opts.ZeroNil,
+		h:               opts.Hasher,
+		tag:             opts.TagName,
+		zeronil:         opts.ZeroNil,
+		numericCoercion: opts.NumericCoercion,
+		skipNilPointers: opts.SkipNilPointers,
+
+		skipNilInterfaces: opts.SkipNilInterfaces,
+		interfaceHandlers: opts.InterfaceHandlers,
+		collectErrors:     opts.CollectErrors,
+		trackPath:         opts.CollectErrors || opts.RejectFloats || opts.RoundFloatsToInt || opts.PanicOnNonDeterministic,
+		protoMessages:     opts.ProtoMessages,
+		normalizeTime:     opts.NormalizeTime,
+		domain:            opts.Domain,
+		version:           opts.Version,
+		iterative:         opts.Iterative,
+		fieldFilter:       opts.FieldFilter,
+		flattenEmbedded:   opts.FlattenEmbedded,
+		order:             opts.ByteOrder,
+
+		includeInterfaceType: opts.IncludeInterfaceType,
+		orderedFields:        opts.OrderedFields,
+		preHash:              opts.PreHash,
+		jsonOmitEmpty:        opts.JSONOmitEmpty,
+		timeZoneSensitive:    opts.TimeZoneSensitive,
+		useGoStringer:        opts.UseGoStringer,
+		defaultPrototypes:    opts.DefaultPrototypes,
+		fieldOrderFunc:       opts.FieldOrderFunc,
+		mapCanonicalJSON:     opts.MapCanonicalJSON,
+		drainChannels:        opts.DrainChannels,
+		structNameFunc:       opts.StructNameFunc,
+		fieldNameFromTag:     opts.FieldNameFromTag,
+		normalizeFieldNames:  opts.NormalizeFieldNames,
+		bulkNumbers:          opts.BulkNumbers,
+		stats:                opts.CombineStats,
+		onCollisionProbe:     opts.OnCollisionProbe,
+		anonymousStructs:     opts.AnonymousStructs,
+		includeUnexported:    opts.IncludeUnexported,
+		maxMapEntries:        opts.MaxMapEntries,
+		mapSets:              opts.MapSets,
+		useGetters:           opts.UseGetters,
+		getterFilter:         opts.GetterFilter,
+
+		pointerValueEquivalent: opts.PointerValueEquivalent,
+		nonZeroEmptyStructs:    opts.NonZeroEmptyStructs,
+		unsafeFastPath:         opts.UnsafeFastPath,
+		headerMaps:             opts.HeaderMaps,
+		errorsAsString:         opts.ErrorsAsString,
+		rejectFloats:           opts.RejectFloats,
+		fieldSalting:           opts.FieldSalting,
+
+		commonTypeCanonicalization: opts.CommonTypeCanonicalization,
+		panicOnNonDeterministic:    opts.PanicOnNonDeterministic,
+		fieldNameHasher:            opts.FieldNameHasher,
+		packageHandlers:            registeredPackages(),
+
+		gobRoundTrip:           opts.GobRoundTrip,
+		stdlibCanonicalization: opts.StdlibCanonicalization,
+		includeMethodSet:       opts.IncludeMethodSet,
+		mapValueTransform:      opts.MapValueTransform,
+		orderedCombiner:        opts.OrderedCombiner,
+		roundFloatsToInt:       opts.RoundFloatsToInt,
+
+		treatEmptyAndAbsentEqual: opts.TreatEmptyAndAbsentEqual,
+		preferJSON:               opts.PreferJSON,
+		fieldAliases:             opts.FieldAliases,
+	}
+	switch opts.UnicodeNormalization {
+	case NormalizeNFC:
+		w.normalize, w.norm = true, norm.NFC
+	case NormalizeNFD:
+		w.normalize, w.norm = true, norm.NFD
+	case NormalizeNFKC:
+		w.normalize, w.norm = true, norm.NFKC
+	case NormalizeNFKD:
+		w.normalize, w.norm = true, norm.NFKD
+	}
+	if opts.OnlyFields != nil {
+		w.onlyFields = make(map[string]struct{}, len(opts.OnlyFields))
+		for _, name := range opts.OnlyFields {
+			w.onlyFields[name] = struct{}{}
+		}
 	}
-	return w.visit(reflect.ValueOf(v), visitOpts{})
+	w.jsonFast = w.jsonFastPath()
+	w.custom = len(w.interfaceHandlers) > 0 || len(w.packageHandlers) > 0 || w.commonTypeCanonicalization ||
+		w.stdlibCanonicalization || w.errorsAsString || w.useGoStringer
+	return w, nil
 }
 
 type walker struct {
-	h       hash.Hash64
-	tag     string
-	zeronil bool
+	h               hash.Hash64
+	tag             string
+	zeronil         bool
+	onlyFields      map[string]struct{}
+	numericCoercion bool
+	skipNilPointers bool
+
+	skipNilInterfaces bool
+	interfaceHandlers []InterfaceHandler
+
+	// collectErrors enables collecting field errors into errs instead of
+	// returning them. path is the path of the value being visited, which
+	// is only tracked if trackPath is set.
+	collectErrors bool
+	errs          []*ErrField
+	trackPath     bool
+	path          []string
+
+	// onVisit, if set, is called with the path and hash of every value
+	// once it is visited, for HashReport.
+	onVisit func(path []string, h uint64)
+
+	// fieldHashes, if set, receives the hashes of the fields of the
+	// top-level struct, for FieldHashes.
+	fieldHashes map[string]uint64
+
+	// jsonFast is the result of jsonFastPath for the options, which must
+	// be updated when a field it depends on is set after newWalker.
+	jsonFast bool
+
+	// custom is whether any of the handling of visitCustom other than
+	// that of reflect.Type is enabled.
+	custom bool
+
+	protoMessages bool
+	normalizeTime bool
+	domain        string
+	version       uint64
+	iterative     bool
+
+	fieldFilter     func(reflect.Type, reflect.StructField, reflect.Value) (bool, error)
+	flattenEmbedded bool
+	order           binary.ByteOrder
+
+	includeInterfaceType bool
+	orderedFields        bool
+	preHash              func(reflect.Value) (uint64, bool, error)
+	jsonOmitEmpty        bool
+	timeZoneSensitive    bool
+	useGoStringer        bool
+	defaultPrototypes    map[reflect.Type]interface{}
+	fieldOrderFunc       func(reflect.Type) []string
+	mapCanonicalJSON     bool
+	drainChannels        bool
+	structNameFunc       func(reflect.Type) string
+	fieldNameFromTag     string
+	normalizeFieldNames  func(string) string
+	bulkNumbers          bool
+	stats                *CombineStats
+	onCollisionProbe     func(a, b, result uint64)
+	anonymousStructs     bool
+	includeUnexported    bool
+	maxMapEntries        int
+	mapSets              bool
+	useGetters           bool
+	getterFilter         func(string) bool
+
+	pointerValueEquivalent bool
+	nonZeroEmptyStructs    bool
+	unsafeFastPath         bool
+	headerMaps             bool
+	errorsAsString         bool
+	rejectFloats           bool
+	fieldSalting           bool
+
+	commonTypeCanonicalization bool
+	panicOnNonDeterministic    bool
+	fieldNameHasher            func(string) uint64
+	packageHandlers            map[string]func(reflect.Value) (uint64, error)
+
+	gobRoundTrip           bool
+	stdlibCanonicalization bool
+	includeMethodSet       bool
+	mapValueTransform      func(reflect.Value) (reflect.Value, error)
+	orderedCombiner        func(h hash.Hash64, a, b uint64) uint64
+	roundFloatsToInt       bool
+
+	treatEmptyAndAbsentEqual bool
+	preferJSON               bool
+	fieldAliases             map[string]string
+
+	// buf is scratch space for serializing numbers, so hashing them
+	// doesn't allocate.
+	buf [16]byte
+
+	// depth is the nesting depth of the walk. Once it is deep enough,
+	// visiting holds the pointers, maps and slices being visited, to
+	// detect cycles.
+	depth    int
+	visiting map[cycleKey]struct{}
+
+	// norm is the Unicode normalization form of strings, if normalize is
+	// true.
+	normalize bool
+	norm      norm.Form
+
+	// canonical enables recording the canonical text of each visited
+	// value. After each visit, text holds the text of that value.
+	canonical bool
+	text      string
+
+	// reflectJSON disables hashJSON, so tests can compare it with the
+	// reflective walk.
+	reflectJSON bool
 }
 
 type visitOpts struct {
@@ -107,6 +1033,67 @@ type visitOpts struct {
 }
 
 func (w *walker) visit(v reflect.Value, opts visitOpts) (uint64, error) {
+	if w.gobRoundTrip && opts.Flags&visitFlagRoot != 0 {
+		var err error
+		if v, err = gobRoundTrip(v); err != nil {
+			return 0, err
+		}
+	}
+	if w.preferJSON && opts.Flags&visitFlagRoot != 0 {
+		s, err := preferredJSON(v)
+		if err != nil {
+			return 0, err
+		}
+		return w.visitValue(reflect.ValueOf(s), visitOpts{})
+	}
+
+	// Type and field names aren't values, so they skip PreHash
+	if opts.Flags&visitFlagName == 0 {
+		if h, ok, err := w.callPreHash(v); err != nil || ok {
+			return h, err
+		}
+	}
+
+	// Values can only be cyclic through pointers, maps and slices. Like
+	// encoding/json, only start tracking them once the walk is deep, so
+	// the common case doesn't pay for it.
+	w.depth++
+	var key cycleKey
+	tracked := false
+	if w.depth > startDetectingCyclesAfter {
+		var cycle bool
+		if key, tracked, cycle = w.enterCycle(v); cycle {
+			w.depth--
+			return w.hashCycle(key.t), nil
+		}
+	}
+
+	// Values decoded from JSON are common and costly to walk by
+	// reflection, so hash them directly when nothing depends on it.
+	var h uint64
+	var err error
+	fast := false
+	if w.jsonFast && !tracked && opts.Struct == nil && opts.Flags&^visitFlagRoot == 0 && w.onlyFields == nil {
+		h, fast, err = w.hashJSON(v)
+	}
+	if !fast {
+		h, err = w.visitValue(v, opts)
+	}
+	if err == nil && w.includeInterfaceType && v.Kind() == reflect.Interface && !v.IsNil() {
+		h = w.hashInterfaceType(v.Elem().Type(), h)
+	}
+	if err == nil && w.onVisit != nil && opts.Flags&visitFlagName == 0 {
+		w.onVisit(w.path, h)
+	}
+
+	if tracked {
+		w.leaveCycle(key)
+	}
+	w.depth--
+	return h, err
+}
+
+func (w *walker) visitValue(v reflect.Value, opts visitOpts) (uint64, error) {
 	t := reflect.TypeOf(0)
 
 	// Loop since these can be wrapped in multiple layers of pointers
@@ -116,10 +1103,22 @@ func (w *walker) visit(v reflect.Value, opts visitOpts) (uint64, error) {
 		// here because it might be a nil in there and the check below must
 		// catch that.
 		if v.Kind() == reflect.Interface {
+			if w.errorsAsString && v.IsNil() && v.Type() == errorType {
+				return w.hashNil(errorType), nil
+			}
 			v = v.Elem()
 			continue
 		}
 
+		if p, ok := w.addressed(v); ok {
+			if h, ok, err := w.visitCustom(p, opts); err != nil || ok {
+				return h, err
+			}
+		}
+		if h, ok, err := w.visitCustom(v, opts); err != nil || ok {
+			return h, err
+		}
+
 		if v.Kind() == reflect.Ptr {
 			if w.zeronil {
 				t = v.Type().Elem()
@@ -138,28 +1137,65 @@ func (w *walker) visit(v reflect.Value, opts visitOpts) (uint64, error) {
 
 	k := v.Kind()
 
+	if w.panicOnNonDeterministic {
+		w.checkDeterministic(v)
+	}
+
+	if w.normalizeTime && v.Type() == timeType {
+		return w.hashTime(v.Interface().(time.Time)), nil
+	}
+	if w.timeZoneSensitive && v.Type() == timeType {
+		return w.hashTimeZone(v.Interface().(time.Time)), nil
+	}
+
+	root := (opts.Flags & visitFlagRoot) != 0
+	if root && w.onlyFields != nil && k != reflect.Struct {
+		return 0, fmt.Errorf("hashstructure: OnlyFields requires a struct, got %s", k)
+	}
+
+	if w.rejectFloats && (k == reflect.Float32 || k == reflect.Float64) {
+		return 0, &ErrFloat{Path: strings.TrimPrefix(strings.Join(w.path, ""), "."), Kind: k}
+	}
+
+	if w.roundFloatsToInt && (k == reflect.Float32 || k == reflect.Float64) {
+		return w.hashRoundedFloat(v.Float())
+	}
+
 	// We can shortcut numeric values by directly binary writing them
 	if k >= reflect.Bool && k <= reflect.Complex64 {
+		if w.numericCoercion {
+			if i, neg, ok := coerceInteger(v); ok {
+				if w.canonical {
+					w.text = canonicalInteger(i, neg)
+				}
+				if neg {
+					return w.hashNegative(i), nil
+				}
+				return w.hash64(i), nil
+			}
+		}
+
+		if w.canonical {
+			w.text = canonicalNumber(v)
+		}
+
 		// A direct hash calculation
-		return hashNumber(w.h, v.Interface()), nil
+		return w.hashNumber(v), nil
 	}
 
 	switch k {
 	case reflect.Array:
-		var h uint64
-		l := v.Len()
-		for i := 0; i < l; i++ {
-			current, err := w.visit(v.Index(i), visitOpts{})
+		return w.visitSeq(v, false, visitOpts{})
+
+	case reflect.Map:
+		if w.mapCanonicalJSON && v.Type().Key().Kind() == reflect.String {
+			s, err := canonicalJSON(v)
 			if err != nil {
 				return 0, err
 			}
-
-			h = hashUpdateOrdered(w.h, h, current)
+			return w.visitValue(reflect.ValueOf(s), visitOpts{})
 		}
 
-		return h, nil
-
-	case reflect.Map:
 		var includeMap IncludableMap
 		if opts.Struct != nil {
 			if v, ok := opts.Struct.(IncludableMap); ok {
@@ -167,238 +1203,1289 @@ func (w *walker) visit(v reflect.Value, opts visitOpts) (uint64, error) {
 			}
 		}
 
+		keys, ordered, err := mapKeys(v)
+		if err != nil {
+			return 0, err
+		}
+		if w.mapSets && !ordered && isEmptyStruct(v.Type().Elem()) {
+			return w.visitMapSet(v, keys, includeMap, opts.StructField)
+		}
+		if w.headerMaps && !ordered && isHeaderMap(v.Type()) {
+			return w.visitHeaderMap(v, keys)
+		}
+		if w.treatEmptyAndAbsentEqual {
+			keys = nonEmptyMapKeys(v, keys)
+		}
+		n := len(keys)
+		truncated := w.maxMapEntries > 0 && n > w.maxMapEntries
+		switch {
+		case truncated:
+			keys, err = w.limitMapKeys(keys, ordered)
+		case w.onVisit != nil && !ordered:
+			// The hash doesn't depend on the order of the keys, but the
+			// report of HashReport lists them in the order they are
+			// visited, so keep it stable.
+			keys, err = w.sortKeysByHash(keys)
+		}
+		if err != nil {
+			return 0, err
+		}
+
 		// Build the hash for the map. We do this by XOR-ing all the key
-		// and value hashes. This makes it deterministic despite ordering.
+		// and value hashes in the order of their sorted hashes. This makes
+		// it deterministic despite ordering, even if the combiner wasn't
+		// commutative. Maps that declare a key order are instead hashed in
+		// that order.
 		var h uint64
-		for _, k := range v.MapKeys() {
+		var hashes []uint64
+		var texts []string
+		for _, k := range keys {
 			v := v.MapIndex(k)
-			if includeMap != nil {
-				incl, err := includeMap.HashIncludeMap(
-					opts.StructField, k.Interface(), v.Interface())
-				if err != nil {
+			incl, err := includeMapEntry(includeMap, opts.StructField, k, v)
+			if err != nil {
+				return 0, err
+			}
+			if !incl {
+				continue
+			}
+
+			if w.mapValueTransform != nil {
+				if v, err = w.mapValueTransform(v); err != nil {
 					return 0, err
 				}
-				if !incl {
-					continue
-				}
+			}
+
+			incl, err = w.selfIncluded(v)
+			if err != nil {
+				return 0, err
+			}
+			if !incl {
+				continue
 			}
 
 			kh, err := w.visit(k, visitOpts{})
 			if err != nil {
 				return 0, err
 			}
+			ktext := w.text
+			if w.trackPath {
+				w.pushPath(fmt.Sprintf("[%v]", k))
+			}
 			vh, err := w.visit(v, visitOpts{})
+			w.popPath()
 			if err != nil {
 				return 0, err
 			}
+			if w.canonical {
+				texts = append(texts, ktext+": "+w.text)
+			}
 
-			fieldHash := hashUpdateOrdered(w.h, kh, vh)
-			h = hashUpdateUnordered(h, fieldHash)
+			fieldHash := w.hashUpdateOrdered(kh, vh)
+			if ordered {
+				h = w.hashUpdateOrdered(h, fieldHash)
+			} else {
+				hashes = append(hashes, fieldHash)
+			}
 		}
 
-		return h, nil
+		h = w.foldMapEntries(h, hashes)
+		if truncated {
+			h = w.hashUpdateOrdered(h, w.hash64(uint64(n)))
+		}
 
-	case reflect.Struct:
-		parent := v.Interface()
-		var include Includable
-		if impl, ok := parent.(Includable); ok {
-			include = impl
+		if w.canonical {
+			if ordered {
+				w.text = canonicalList("map[", texts, "]", false)
+			} else {
+				w.text = canonicalList("map{", texts, "}", true)
+			}
+			if truncated {
+				w.text += "(of " + strconv.Itoa(n) + ")"
+			}
 		}
+		return h, nil
 
+	case reflect.Struct:
 		t := v.Type()
-		h, err := w.visit(reflect.ValueOf(t.Name()), visitOpts{})
+		name := t.Name()
+		switch {
+		case w.structNameFunc != nil:
+			name = w.structNameFunc(t)
+		case w.anonymousStructs && name == "":
+			name = t.String()
+		}
+		h, err := w.hashName(name)
 		if err != nil {
 			return 0, err
 		}
 
-		l := v.NumField()
-		for i := 0; i < l; i++ {
-			if innerV := v.Field(i); v.CanSet() || t.Field(i).Name != "_" {
-				var f visitFlag
-				fieldType := t.Field(i)
-				if fieldType.PkgPath != "" {
-					// Unexported
-					continue
+		// Only the top-level struct is filtered by OnlyFields, so make
+		// sure every requested field actually exists on it.
+		onlyFields := root && w.onlyFields != nil
+		if onlyFields && !w.useGetters {
+			for name := range w.onlyFields {
+				if f, ok := t.FieldByName(name); !ok || (len(f.Index) != 1 && !w.flattenEmbedded) {
+					return 0, fmt.Errorf("hashstructure: OnlyFields contains unknown field %q of %s", name, t)
 				}
+			}
+		}
 
-				tag := fieldType.Tag.Get(w.tag)
-				if tag == "ignore" || tag == "-" {
-					// Ignore this field
-					continue
-				}
+		acc := &fieldAcc{h: h}
+		if w.fieldOrderFunc != nil {
+			acc.order = w.fieldOrderFunc(t)
+		}
+		if root {
+			acc.record = w.fieldHashes
+		}
+		if w.useGetters {
+			err = w.visitGetters(v, onlyFields, acc)
+		} else {
+			err = w.visitFields(v, onlyFields, acc)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if w.nonZeroEmptyStructs && acc.n == 0 {
+			// Nothing but the name was hashed, so tell the type apart
+			// by its full string instead. The string of an anonymous
+			// struct lists its skipped fields, which must not count.
+			typ := t.String()
+			if t.Name() == "" {
+				typ = "struct {}"
+			}
+			w.h.Reset()
+			_, _ = w.h.Write([]byte(typ))
+			acc.h = w.hashUpdateOrdered(acc.h, w.h.Sum64())
+		}
+		if w.includeMethodSet && reflect.PtrTo(t).NumMethod() > 0 {
+			acc.h = w.hashUpdateOrdered(acc.h, w.hashMethodSet(t))
+		}
+		return w.fieldsDone(name, acc), nil
 
-				// if string is set, use the string value
-				if tag == "string" {
-					if impl, ok := innerV.Interface().(fmt.Stringer); ok {
-						innerV = reflect.ValueOf(impl.String())
-					} else {
-						return 0, &ErrNotStringer{
-							Field: v.Type().Field(i).Name,
-						}
-					}
-				}
+	case reflect.Slice:
+		// We have two behaviors here. If it isn't a set, then we just
+		// visit all the elements. If it is a set, then we do a deterministic
+		// hash code that still counts duplicate elements.
+		if opts.Flags&visitFlagSet != 0 {
+			return w.visitSeq(v, true, visitOpts{Struct: opts.Struct, StructField: opts.StructField})
+		}
+		if opts.Flags&visitFlagRevSet != 0 {
+			return w.visitRevSet(v)
+		}
+		return w.visitSeq(v, false, visitOpts{})
 
-				// Check if we implement includable and check it
-				if include != nil {
-					incl, err := include.HashInclude(fieldType.Name, innerV)
-					if err != nil {
-						return 0, err
-					}
-					if !incl {
-						continue
-					}
-				}
+	case reflect.String:
+		// Directly hash
+		return w.hashString(v.String())
 
-				switch tag {
-				case "set":
-					f |= visitFlagSet
-				}
+	case reflect.Chan:
+		if !w.drainChannels {
+			return 0, fmt.Errorf("unknown kind to hash: %s", k)
+		}
+		elems, err := drainChan(v)
+		if err != nil {
+			return 0, err
+		}
+		return w.visitSeq(elems, false, visitOpts{})
 
-				kh, err := w.visit(reflect.ValueOf(fieldType.Name), visitOpts{})
-				if err != nil {
-					return 0, err
-				}
+	default:
+		return 0, fmt.Errorf("unknown kind to hash: %s", k)
+	}
 
-				vh, err := w.visit(innerV, visitOpts{
-					Flags:       f,
-					Struct:      parent,
-					StructField: fieldType.Name,
-				})
-				if err != nil {
-					return 0, err
-				}
+}
 
-				fieldHash := hashUpdateOrdered(w.h, kh, vh)
-				h = hashUpdateUnordered(h, fieldHash)
-			}
-		}
+// hashString hashes the string s, which visitValue does for every value
+// of kind string.
+func (w *walker) hashString(s string) (uint64, error) {
+	w.h.Reset()
+	if w.normalize {
+		s = w.norm.String(s)
+	}
+	if w.canonical {
+		w.text = strconv.Quote(s)
+	}
+	// avoid allocating a new byte slice for the string
+	_, err := w.h.Write(*(*[]byte)(unsafe.Pointer(&s)))
+	return w.h.Sum64(), err
+}
 
-		return h, nil
+// hashName hashes a type or field name like visit does for a string with
+// visitFlagName. Only InterfaceHandlers can handle a string, so without
+// them the name is hashed directly instead of by reflection.
+func (w *walker) hashName(name string) (uint64, error) {
+	if len(w.interfaceHandlers) == 0 {
+		return w.hashString(name)
+	}
+	return w.visit(reflect.ValueOf(name), visitOpts{Flags: visitFlagName})
+}
 
-	case reflect.Slice:
-		// We have two behaviors here. If it isn't a set, then we just
-		// visit all the elements. If it is a set, then we do a deterministic
-		// hash code.
-		var h uint64
-		set := (opts.Flags & visitFlagSet) != 0
-		l := v.Len()
-		for i := 0; i < l; i++ {
-			current, err := w.visit(v.Index(i), visitOpts{})
-			if err != nil {
-				return 0, err
-			}
+// finish returns the error of a walk, which is either err or the field
+// errors collected during the walk.
+func (w *walker) finish(err error) error {
+	if err == nil && len(w.errs) > 0 {
+		err = &ErrFields{Errors: w.errs}
+	}
+	w.errs = nil
+	return err
+}
 
-			if set {
-				h = hashUpdateUnordered(h, current)
-			} else {
-				h = hashUpdateOrdered(w.h, h, current)
-			}
+// hashTime returns the hash of the instant t in UTC.
+func (w *walker) hashTime(t time.Time) uint64 {
+	t = t.UTC()
+	if w.canonical {
+		w.text = "time(" + t.Format(time.RFC3339Nano) + ")"
+	}
+	return w.hashUpdateOrdered(uint64(t.Unix()), uint64(t.Nanosecond()))
+}
+
+// hashTimeZone returns the hash of the instant t together with its zone.
+func (w *walker) hashTimeZone(t time.Time) uint64 {
+	name, offset := t.Zone()
+	if w.canonical {
+		w.text = "time(" + t.Format(time.RFC3339Nano) + " " + name + ")"
+	}
+
+	w.h.Reset()
+	_, _ = w.h.Write([]byte(name))
+	h := w.hashUpdateOrdered(w.h.Sum64(), uint64(int64(offset)))
+	h = w.hashUpdateOrdered(h, uint64(t.Unix()))
+	return w.hashUpdateOrdered(h, uint64(t.Nanosecond()))
+}
+
+// drainChan returns a slice of the elements buffered in the channel v,
+// which it receives and then sends again in the same order.
+func drainChan(v reflect.Value) (elems reflect.Value, err error) {
+	if v.Type().ChanDir() != reflect.BothDir {
+		return reflect.Value{}, fmt.Errorf("hashstructure: cannot drain directional channel %s", v.Type())
+	}
+
+	n := v.Len()
+	elems = reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, n)
+	for i := 0; i < n; i++ {
+		elem, ok := v.TryRecv()
+		if !ok {
+			break
 		}
+		elems = reflect.Append(elems, elem)
+	}
 
-		return h, nil
+	// Sending on a closed channel panics, and the received elements
+	// are lost in that case.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("hashstructure: cannot restore channel %s: %v", v.Type(), r)
+		}
+	}()
+	for i := 0; i < elems.Len(); i++ {
+		if !v.TrySend(elems.Index(i)) {
+			return reflect.Value{}, fmt.Errorf("hashstructure: cannot restore channel %s: it is full", v.Type())
+		}
+	}
+	return elems, nil
+}
 
-	case reflect.String:
-		// Directly hash
+// canonicalJSON returns the JSON encoding of v with sorted map keys and
+// without HTML escaping.
+func canonicalJSON(v reflect.Value) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v.Interface()); err != nil {
+		return "", fmt.Errorf("hashstructure: error encoding %s as JSON: %s", v.Type(), err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// preferredJSON returns the canonical JSON encoding of v for PreferJSON.
+// It is decoded and encoded again, so that objects encoded from structs
+// get sorted keys like those of maps.
+func preferredJSON(v reflect.Value) (string, error) {
+	var x interface{}
+	if v.IsValid() {
+		x = v.Interface()
+	}
+	data, err := json.Marshal(x)
+	if err != nil {
+		return "", fmt.Errorf("hashstructure: error encoding %s as JSON: %s", v.Type(), err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return "", fmt.Errorf("hashstructure: error decoding the JSON of %s: %s", v.Type(), err)
+	}
+	return canonicalJSON(reflect.ValueOf(&tree).Elem())
+}
+
+// final returns the final hash value for the hash h of the walked value.
+func (w *walker) final(h uint64) uint64 {
+	if w.domain != "" {
 		w.h.Reset()
-		s := v.String()
-		// avoid allocating a new byte slice for the string
-		_, err := w.h.Write(*(*[]byte)(unsafe.Pointer(&s)))
-		return w.h.Sum64(), err
+		_, _ = w.h.Write([]byte(w.domain))
+		h = w.hashUpdateOrdered(w.h.Sum64(), h)
+	}
+	if w.version != 0 {
+		h = w.hashUpdateOrdered(w.hash64(w.version), h)
+	}
+	return h
+}
+
+// pushPath adds a segment to the path of the value being visited.
+func (w *walker) pushPath(segment string) {
+	if w.trackPath {
+		w.path = append(w.path, segment)
+	}
+}
+
+// popPath removes the last segment added with pushPath.
+func (w *walker) popPath() {
+	if w.trackPath {
+		w.path = w.path[:len(w.path)-1]
+	}
+}
+
+// truncatePath removes segments from the path until it has depth segments.
+func (w *walker) truncatePath(depth int) {
+	if w.trackPath {
+		w.path = w.path[:depth]
+	}
+}
+
+// fieldError handles an error hashing the given field of the struct being
+// visited. If errors are collected, it records the error and returns nil
+// so the field is skipped. Otherwise it returns err.
+func (w *walker) fieldError(field string, err error) error {
+	if !w.collectErrors {
+		return err
+	}
+
+	path := strings.TrimPrefix(strings.Join(w.path, "")+"."+field, ".")
+	w.errs = append(w.errs, &ErrField{Path: path, Err: err})
+	return nil
+}
+
+// hashNil returns the sentinel hash used for nil values of type t that
+// shouldn't be treated like a zero value.
+func (w *walker) hashNil(t reflect.Type) uint64 {
+	name := t.String()
+	if w.canonical {
+		w.text = "nil(" + name + ")"
+	}
+
+	w.h.Reset()
+	_, _ = w.h.Write([]byte(name))
+	return w.hashUpdateOrdered(nilSentinel, w.h.Sum64())
+}
+
+// hashInterfaceType folds the concrete type t of a value held in an
+// interface into its hash h.
+func (w *walker) hashInterfaceType(t reflect.Type, h uint64) uint64 {
+	for w.pointerValueEquivalent && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name := t.String()
+	if w.canonical {
+		w.text = "iface(" + name + ", " + w.text + ")"
+	}
+
+	w.h.Reset()
+	_, _ = w.h.Write([]byte(name))
+	return w.hashUpdateOrdered(w.h.Sum64(), h)
+}
+
+// visitCustom hashes v with the first of InterfaceHandlers, the handlers
+// of RegisterPackage, the handling of reflect.Type,
+// CommonTypeCanonicalization, ErrorsAsString and UseGoStringer that
+// applies to it, and returns whether one did. It is
+// called for every layer of pointers, so a handler for *T takes precedence
+// over one for T. See Hash for the full order of precedence.
+func (w *walker) visitCustom(v reflect.Value, opts visitOpts) (uint64, bool, error) {
+	// By default only reflect.Type is handled, so don't look for the rest
+	if !w.custom && !isReflectType(v) {
+		return 0, false, nil
+	}
+
+	fn := w.interfaceHandler(v)
+	if fn == nil {
+		fn = w.packageHandler(v)
+	}
+	if fn != nil {
+		h, err := fn(v)
+		if err != nil {
+			return 0, false, err
+		}
+		if w.canonical {
+			w.text = v.Type().String() + "(#" + strconv.FormatUint(h, 10) + ")"
+		}
+		return h, true, nil
+	}
+
+	// The implementation of reflect.Type has no exported fields, so hash
+	// its name instead.
+	if isReflectType(v) {
+		h, err := w.visitValue(reflect.ValueOf(v.Interface().(reflect.Type).String()), visitOpts{})
+		return h, err == nil, err
+	}
+
+	if w.commonTypeCanonicalization {
+		s, ok, err := commonTypeText(v, opts.StructField)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			h, err := w.visitValue(reflect.ValueOf(s), visitOpts{})
+			return h, err == nil, err
+		}
+	}
+
+	if h, ok, err := w.visitStdlib(v); err != nil || ok {
+		return h, ok, err
+	}
+
+	if e, ok := w.errorString(v); ok {
+		var s string
+		if err := callSafely(opts.StructField, "Error", func() error {
+			s = e.Error()
+			return nil
+		}); err != nil {
+			return 0, false, err
+		}
+		h, err := w.visitValue(reflect.ValueOf(s), visitOpts{})
+		return h, err == nil, err
+	}
+
+	if gs, ok := w.goStringer(v); ok {
+		var s string
+		if err := callSafely(opts.StructField, "GoString", func() error {
+			s = gs.GoString()
+			return nil
+		}); err != nil {
+			return 0, false, err
+		}
+		h, err := w.visitValue(reflect.ValueOf(s), visitOpts{})
+		return h, err == nil, err
+	}
 
+	return 0, false, nil
+}
+
+// customHandled returns whether visitCustom would hash v, without hashing
+// it. Walks that don't visit every nested value, like the one of
+// Iterative, use it to leave such values to visitCustom.
+func (w *walker) customHandled(v reflect.Value) bool {
+	if w.interfaceHandler(v) != nil || w.packageHandler(v) != nil {
+		return true
+	}
+	if isReflectType(v) {
+		return true
+	}
+	if v.IsValid() && v.CanInterface() {
+		if w.commonTypeCanonicalization && isCommonType(v.Type()) {
+			return true
+		}
+		if w.stdlibCanonicalization && w.isStdlibType(v.Type()) {
+			return true
+		}
+	}
+	if _, ok := w.errorString(v); ok {
+		return true
+	}
+	_, ok := w.goStringer(v)
+	return ok
+}
+
+// isReflectType returns whether v is a reflect.Type, which visitCustom
+// hashes by its name. The implementations of reflect.Type are pointers, so
+// other kinds are ruled out before the costlier check of the method set.
+func isReflectType(v reflect.Value) bool {
+	return v.Kind() == reflect.Ptr && !v.IsNil() && v.CanInterface() && v.Type().Implements(reflectTypeType)
+}
+
+// callPreHash calls PreHash for v, if set, and returns whether it handled
+// v.
+func (w *walker) callPreHash(v reflect.Value) (uint64, bool, error) {
+	if w.preHash == nil {
+		return 0, false, nil
+	}
+
+	h, ok, err := w.preHash(v)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	if w.canonical {
+		w.text = "prehash(#" + strconv.FormatUint(h, 10) + ")"
+	}
+	return h, true, nil
+}
+
+// visitElem visits an element of a slice or array with opts. Nil
+// interface elements, and unless ZeroNil is set nil pointer elements, hash
+// as a sentinel so they don't collide with elements holding a zero value.
+func (w *walker) visitElem(v reflect.Value, opts visitOpts) (uint64, error) {
+	if (v.Kind() == reflect.Interface || (!w.zeronil && v.Kind() == reflect.Ptr)) && v.IsNil() {
+		if h, ok, err := w.callPreHash(v); err != nil || ok {
+			return h, err
+		}
+		return w.hashNil(v.Type()), nil
+	}
+	return w.visit(v, opts)
+}
+
+// checkDeterministic panics for PanicOnNonDeterministic if v can't be
+// hashed deterministically.
+func (w *walker) checkDeterministic(v reflect.Value) {
+	switch {
+	case v.Kind() == reflect.Chan && !w.drainChannels:
+	case v.Kind() == reflect.Func, v.Kind() == reflect.UnsafePointer:
+	case v.Type() == syncMapType:
 	default:
-		return 0, fmt.Errorf("unknown kind to hash: %s", k)
+		return
+	}
+
+	path := strings.TrimPrefix(strings.Join(w.path, ""), ".")
+	if path == "" {
+		path = "the root"
+	}
+	panic(fmt.Sprintf("hashstructure: %s at %s can't be hashed deterministically; "+
+		"register a handler for it or leave it out of the hash", v.Type(), path))
+}
+
+// commonTypeText returns the canonical text of v for
+// CommonTypeCanonicalization, if it is of one of the common types.
+func commonTypeText(v reflect.Value, field string) (string, bool, error) {
+	if !v.IsValid() || !v.CanInterface() {
+		return "", false, nil
+	}
+
+	t := v.Type()
+	if !isCommonType(t) {
+		return "", false, nil
+	}
+
+	// Copy the value so methods with pointer receivers are found too
+	p := reflect.New(t)
+	p.Elem().Set(v)
+
+	var s string
+	var err error
+	if tm, ok := p.Interface().(encoding.TextMarshaler); ok {
+		err = callSafely(field, "MarshalText", func() error {
+			text, err := tm.MarshalText()
+			s = string(text)
+			return err
+		})
+	} else {
+		err = callSafely(field, "String", func() error {
+			s = p.Interface().(fmt.Stringer).String()
+			return nil
+		})
+	}
+	return s, err == nil, err
+}
+
+// isCommonType returns whether t is one of the common types of
+// CommonTypeCanonicalization: a 16 byte array with a MarshalText method,
+// like the UUID types, or net/mail.Address.
+func isCommonType(t reflect.Type) bool {
+	switch {
+	case t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8:
+		return reflect.PtrTo(t).Implements(textMarshalerType)
+	case t.Kind() == reflect.Struct && t.PkgPath() == "net/mail" && t.Name() == "Address":
+		return true
+	}
+	return false
+}
+
+// errorString returns v as an error if it is hashed by its Error method
+// because of ErrorsAsString.
+func (w *walker) errorString(v reflect.Value) (error, bool) {
+	if !w.errorsAsString || !v.IsValid() || !v.CanInterface() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return nil, false
+	}
+
+	e, ok := v.Interface().(error)
+	return e, ok
+}
+
+// goStringer returns v as a fmt.GoStringer if it is hashed by its GoString
+// method because of UseGoStringer.
+func (w *walker) goStringer(v reflect.Value) (fmt.GoStringer, bool) {
+	if !w.useGoStringer || !v.IsValid() || !v.CanInterface() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return nil, false
+	}
+
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if (w.normalizeTime || w.timeZoneSensitive) && t == timeType {
+		return nil, false
+	}
+
+	gs, ok := v.Interface().(fmt.GoStringer)
+	return gs, ok
+}
+
+// interfaceHandler returns the handler function for v if its type
+// implements the interface of one of the InterfaceHandlers.
+func (w *walker) interfaceHandler(v reflect.Value) func(reflect.Value) (uint64, error) {
+	if len(w.interfaceHandlers) == 0 || !v.IsValid() {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+
+	for _, h := range w.interfaceHandlers {
+		if v.Type().Implements(h.Iface) {
+			return h.Fn
+		}
+	}
+	return nil
+}
+
+// mapKeys returns the keys of the map v and whether they are ordered. If v
+// implements OrderedMapper the keys are in its declared order, which must
+// list every key of the map exactly once.
+func mapKeys(v reflect.Value) ([]reflect.Value, bool, error) {
+	if !v.CanInterface() {
+		return v.MapKeys(), false, nil
+	}
+	om, ok := v.Interface().(OrderedMapper)
+	if !ok {
+		return v.MapKeys(), false, nil
+	}
+
+	var order []interface{}
+	if err := callSafely("", "HashKeyOrder", func() error {
+		order = om.HashKeyOrder()
+		return nil
+	}); err != nil {
+		return nil, false, err
+	}
+	if len(order) != v.Len() {
+		return nil, false, fmt.Errorf("hashstructure: HashKeyOrder of %s returned %d keys for a map with %d entries",
+			v.Type(), len(order), v.Len())
+	}
+
+	kt := v.Type().Key()
+	keys := make([]reflect.Value, 0, len(order))
+	seen := make(map[interface{}]struct{}, len(order))
+	for _, key := range order {
+		k := reflect.ValueOf(key)
+		if !k.IsValid() || !k.Type().ConvertibleTo(kt) {
+			return nil, false, fmt.Errorf("hashstructure: HashKeyOrder of %s returned %#v, which is not a valid key", v.Type(), key)
+		}
+		k = k.Convert(kt)
+		if _, ok := seen[k.Interface()]; ok || !v.MapIndex(k).IsValid() {
+			return nil, false, fmt.Errorf("hashstructure: HashKeyOrder of %s must list every key once, got %#v", v.Type(), key)
+		}
+		seen[k.Interface()] = struct{}{}
+		keys = append(keys, k)
+	}
+	return keys, true, nil
+}
+
+// addressed returns a pointer to a copy of v for PointerValueEquivalent, if
+// v isn't a pointer, so that the methods of a value are the same as those of
+// a pointer to it.
+func (w *walker) addressed(v reflect.Value) (reflect.Value, bool) {
+	if !w.pointerValueEquivalent {
+		return reflect.Value{}, false
+	}
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.CanInterface() || v.Kind() == reflect.Ptr {
+		return reflect.Value{}, false
+	}
+
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p, true
+}
+
+// foldMapEntries folds the hashes of the entries of an unordered map into h
+// in sorted order. Distinct keys can have the same content, such as
+// pointers to equal values, so an entry can hash the same as another. Such
+// duplicates are combined with an ordered update instead of XORing them,
+// so they don't cancel out. Since the hashes are sorted first, the result
+// still doesn't depend on the order of the map.
+func (w *walker) foldMapEntries(h uint64, hashes []uint64) uint64 {
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	for i, fieldHash := range hashes {
+		if i > 0 && fieldHash == hashes[i-1] {
+			h = w.hashUpdateOrdered(h, fieldHash)
+		} else {
+			h = w.hashUpdateUnordered(h, fieldHash)
+		}
+	}
+	return h
+}
+
+// includeMapEntry returns whether the entry of key k and value v of the map
+// in the given field is included by the IncludableMap of the struct, if
+// any.
+func includeMapEntry(include IncludableMap, field string, k, v reflect.Value) (bool, error) {
+	if include == nil {
+		return true, nil
+	}
+
+	var incl bool
+	err := callSafely(field, "HashIncludeMap", func() (err error) {
+		incl, err = include.HashIncludeMap(field, k.Interface(), v.Interface())
+		return err
+	})
+	return incl, err
+}
+
+// visitMapSet hashes the map v, whose values are empty structs, as a set of
+// its keys for MapSets.
+func (w *walker) visitMapSet(v reflect.Value, keys []reflect.Value, include IncludableMap, field string) (uint64, error) {
+	set := reflect.MakeSlice(reflect.SliceOf(v.Type().Key()), 0, len(keys))
+	for _, k := range keys {
+		incl, err := includeMapEntry(include, field, k, v.MapIndex(k))
+		if err != nil {
+			return 0, err
+		}
+		if incl {
+			set = reflect.Append(set, k)
+		}
+	}
+	return w.visitSeq(set, true, visitOpts{})
+}
+
+// visitHeaderMap hashes the map v of type map[string][]string for
+// HeaderMaps. Keys that only differ in case are merged, and their values
+// are hashed as a set.
+func (w *walker) visitHeaderMap(v reflect.Value, keys []reflect.Value) (uint64, error) {
+	values := make(map[string][]string, len(keys))
+	for _, k := range keys {
+		name := strings.ToLower(k.String())
+		vs := v.MapIndex(k)
+		if _, ok := values[name]; !ok {
+			values[name] = nil
+		}
+		for i := 0; i < vs.Len(); i++ {
+			values[name] = append(values[name], vs.Index(i).String())
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var h uint64
+	var hashes []uint64
+	var texts []string
+	for _, name := range names {
+		kh, err := w.visit(reflect.ValueOf(name), visitOpts{})
+		if err != nil {
+			return 0, err
+		}
+		ktext := w.text
+		if w.trackPath {
+			w.pushPath("[" + name + "]")
+		}
+		vh, err := w.visit(reflect.ValueOf(values[name]), visitOpts{Flags: visitFlagSet})
+		w.popPath()
+		if err != nil {
+			return 0, err
+		}
+		if w.canonical {
+			texts = append(texts, ktext+": "+w.text)
+		}
+		hashes = append(hashes, w.hashUpdateOrdered(kh, vh))
+	}
+
+	h = w.foldMapEntries(h, hashes)
+	if w.canonical {
+		w.text = canonicalList("map{", texts, "}", true)
+	}
+	return h, nil
+}
+
+// isHeaderMap returns whether t is a map type like http.Header, with
+// string keys and values that are slices of strings.
+func isHeaderMap(t reflect.Type) bool {
+	return t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Slice && t.Elem().Elem().Kind() == reflect.String
+}
+
+// isEmptyStruct returns whether t is a struct type without fields, like
+// the values of maps used as sets.
+func isEmptyStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 0
+}
+
+// nonEmptyMapKeys returns the keys of the map v whose values aren't empty,
+// for TreatEmptyAndAbsentEqual.
+func nonEmptyMapKeys(v reflect.Value, keys []reflect.Value) []reflect.Value {
+	nonEmpty := make([]reflect.Value, 0, len(keys))
+	for _, k := range keys {
+		e := v.MapIndex(k)
+		if e.Kind() == reflect.Interface && !e.IsNil() {
+			e = e.Elem()
+		}
+		if !isEmptyValue(e) {
+			nonEmpty = append(nonEmpty, k)
+		}
+	}
+	return nonEmpty
+}
+
+// limitMapKeys returns the MaxMapEntries keys of a map that are hashed.
+// These are the first keys if the map is ordered, and otherwise the keys
+// with the lowest hashes, so the choice doesn't depend on iteration order.
+func (w *walker) limitMapKeys(keys []reflect.Value, ordered bool) ([]reflect.Value, error) {
+	if ordered {
+		return keys[:w.maxMapEntries], nil
+	}
+
+	sorted, err := w.sortKeysByHash(keys)
+	if err != nil {
+		return nil, err
+	}
+	return sorted[:w.maxMapEntries], nil
+}
+
+// sortKeysByHash returns the keys of an unordered map sorted by their
+// hashes, which doesn't depend on iteration order.
+func (w *walker) sortKeysByHash(keys []reflect.Value) ([]reflect.Value, error) {
+	hashes := make([]uint64, len(keys))
+	for i, k := range keys {
+		h, err := w.visit(k, visitOpts{})
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = h
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return hashes[order[i]] < hashes[order[j]] })
+
+	sorted := make([]reflect.Value, len(keys))
+	for i := range sorted {
+		sorted[i] = keys[order[i]]
+	}
+	return sorted, nil
+}
+
+// isProtoMessage returns whether pointers to the struct type t have the
+// method set of a generated protobuf message: Reset, String and either
+// ProtoReflect (APIv2) or ProtoMessage (APIv1).
+func isProtoMessage(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	hasMethod := func(name string) bool {
+		m, ok := pt.MethodByName(name)
+		return ok && m.Type.NumIn() == 1
+	}
+	return hasMethod("Reset") && hasMethod("String") &&
+		(hasMethod("ProtoReflect") || hasMethod("ProtoMessage"))
+}
+
+// isProtoBookkeeping returns whether the field of a protobuf message is
+// bookkeeping generated by protoc rather than a field of the message: the
+// XXX_ fields of APIv1 and the internal fields of APIv2.
+func isProtoBookkeeping(field reflect.StructField) bool {
+	if strings.HasPrefix(field.Name, "XXX_") {
+		return true
+	}
+	if field.PkgPath == "" {
+		return false
+	}
+	switch field.Name {
+	case "state", "sizeCache", "unknownFields":
+		return true
+	}
+	return false
+}
+
+// callMethod calls the method with the given name on the struct v and
+// returns its result for hashing in place of the field. Methods with a
+// pointer receiver are called on a copy of v if it isn't addressable, such
+// as a struct passed to Hash by value.
+func callMethod(v reflect.Value, tag, field, name string) (reflect.Value, error) {
+	m := v.MethodByName(name)
+	if !m.IsValid() {
+		if v.CanAddr() {
+			m = v.Addr().MethodByName(name)
+		} else if v.CanInterface() {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			m = p.MethodByName(name)
+		}
+	}
+
+	errInvalid := &ErrInvalidMethod{Field: field, Method: name}
+	if tag != "hash" {
+		errInvalid.Tag = tag
+	}
+	if !m.IsValid() {
+		return reflect.Value{}, errInvalid
+	}
+	return callFunc(m, field, name, errInvalid)
+}
+
+// callField calls the function held by the field for hash:"call" and
+// returns its result for hashing in place of the field.
+func callField(v reflect.Value, tag, field string) (reflect.Value, error) {
+	if v.Kind() != reflect.Func {
+		return reflect.Value{}, fmt.Errorf("hashstructure: %s has %s:\"call\" set, but is a %s", field, tag, v.Kind())
+	}
+	if v.IsNil() {
+		return reflect.Value{}, fmt.Errorf("hashstructure: %s has %s:\"call\" set, but is nil", field, tag)
 	}
 
+	errInvalid := fmt.Errorf("hashstructure: %s has %s:\"call\" set, but is not a function "+
+		"without arguments returning a value and optionally an error", field, tag)
+	return callFunc(v, field, "func", errInvalid)
 }
 
-func hashUpdateOrdered(h hash.Hash64, a, b uint64) uint64 {
-	// For ordered updates, use a real hash function
-	h.Reset()
-	_, _ = h.Write([]byte{
-		byte(a), byte(a >> 8), byte(a >> 16), byte(a >> 24), byte(a >> 32), byte(a >> 40), byte(a >> 48), byte(a >> 56),
-		byte(b), byte(b >> 8), byte(b >> 16), byte(b >> 24), byte(b >> 32), byte(b >> 40), byte(b >> 48), byte(b >> 56),
+// callFunc calls fn, which must take no arguments and return a value and
+// optionally an error, and returns the value. It returns errInvalid if fn
+// doesn't have that signature.
+func callFunc(fn reflect.Value, field, name string, errInvalid error) (reflect.Value, error) {
+	ft := fn.Type()
+	switch {
+	case ft.NumIn() != 0:
+		return reflect.Value{}, errInvalid
+	case ft.NumOut() == 1:
+	case ft.NumOut() == 2 && ft.Out(1) == errorType:
+	default:
+		return reflect.Value{}, errInvalid
+	}
+
+	var out []reflect.Value
+	if err := callSafely(field, name, func() error {
+		out = fn.Call(nil)
+		return nil
+	}); err != nil {
+		return reflect.Value{}, err
+	}
+	if len(out) == 2 && !out[1].IsNil() {
+		return reflect.Value{}, out[1].Interface().(error)
+	}
+	return out[0], nil
+}
+
+// selfIncluded checks whether v implements SelfIncludable and, if so, asks
+// it whether it should be included in the hash. Values equal to their
+// prototype in DefaultPrototypes are never included.
+func (w *walker) selfIncluded(v reflect.Value) (bool, error) {
+	if !v.IsValid() || !v.CanInterface() {
+		return true, nil
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return true, nil
+	}
+
+	if w.defaultPrototypes != nil {
+		dv := v
+		if dv.Kind() == reflect.Interface {
+			dv = dv.Elem()
+		}
+		for w.pointerValueEquivalent && dv.Kind() == reflect.Ptr && !dv.IsNil() {
+			dv = dv.Elem()
+		}
+		if proto, ok := w.defaultPrototypes[dv.Type()]; ok && reflect.DeepEqual(dv.Interface(), proto) {
+			return false, nil
+		}
+	}
+
+	iv := v
+	if iv.Kind() == reflect.Interface {
+		iv = iv.Elem()
+	}
+	if p, ok := w.addressed(iv); ok {
+		iv = p
+	}
+
+	// Check the type first, so other values aren't copied by Interface
+	if !iv.Type().Implements(selfIncludableType) {
+		return true, nil
+	}
+	impl := iv.Interface().(SelfIncludable)
+	var incl bool
+	err := callSafely("", "HashSelfInclude", func() (err error) {
+		incl, err = impl.HashSelfInclude()
+		return err
 	})
-	return h.Sum64()
+	return incl, err
+}
+
+// callSafely calls fn, which calls the method of a hashed value, and turns
+// a panic into an ErrPanic.
+func callSafely(field, method string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &ErrPanic{Field: field, Method: method, Value: r}
+		}
+	}()
+	return fn()
 }
 
-func hashUpdateUnordered(a, b uint64) uint64 {
+func (w *walker) hashUpdateOrdered(a, b uint64) uint64 {
+	var result uint64
+	if w.orderedCombiner != nil {
+		result = w.orderedCombiner(w.h, a, b)
+	} else {
+		// For ordered updates, use a real hash function
+		binary.LittleEndian.PutUint64(w.buf[:8], a)
+		binary.LittleEndian.PutUint64(w.buf[8:], b)
+		w.h.Reset()
+		_, _ = w.h.Write(w.buf[:16])
+		result = w.h.Sum64()
+	}
+
+	if w.stats != nil {
+		w.stats.Ordered++
+	}
+	if w.onCollisionProbe != nil {
+		w.onCollisionProbe(a, b, result)
+	}
+	return result
+}
+
+func (w *walker) hashUpdateUnordered(a, b uint64) uint64 {
+	if w.stats != nil {
+		w.stats.Unordered++
+	}
 	return a ^ b
 }
 
-func hashNumber(h hash.Hash64, i interface{}) uint64 {
-	switch data := i.(type) {
-	case bool:
-		if data {
-			return hash8(h, uint8(1))
-		}
-		return hash8(h, uint8(0))
-	case int8:
-		return hash8(h, uint8(data))
-	case uint8:
-		return hash8(h, data)
-
-	case int16:
-		return hash16(h, uint16(data))
-	case uint16:
-		return hash16(h, data)
-
-	case int32:
-		return hash32(h, uint32(data))
-	case uint32:
-		return hash32(h, data)
-	case float32:
-		return hash32(h, math.Float32bits(data))
-
-	case int:
-		return hash64(h, uint64(data))
-	case int64:
-		return hash64(h, uint64(data))
-	case uint:
-		return hash64(h, uint64(data))
-	case uint64:
-		return hash64(h, data)
-	case uintptr:
-		return hash64(h, uint64(data))
-	case float64:
-		return hash64(h, math.Float64bits(data))
-	case complex64:
-		return hash64(h, *(*uint64)(unsafe.Pointer(&data)))
+// hashUpdateSet is an unordered update like hashUpdateUnordered, except
+// that adding the same value twice doesn't cancel out: b is mixed and then
+// added, so duplicates are counted.
+func (w *walker) hashUpdateSet(a, b uint64) uint64 {
+	if w.stats != nil {
+		w.stats.Set++
+	}
+
+	// splitmix64 finalizer
+	b ^= b >> 30
+	b *= 0xbf58476d1ce4e5b9
+	b ^= b >> 27
+	b *= 0x94d049bb133111eb
+	b ^= b >> 31
+	return a + b
+}
+
+// hashNumber hashes the bool or numeric value v by its kind, so named types
+// hash the same as their underlying type.
+func (w *walker) hashNumber(v reflect.Value) uint64 {
+	if w.unsafeFastPath && v.CanAddr() {
+		return w.hashNumberAt(v.Kind(), unsafe.Pointer(v.UnsafeAddr()))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return w.hash8(1)
+		}
+		return w.hash8(0)
+	case reflect.Int8:
+		return w.hash8(uint8(v.Int()))
+	case reflect.Uint8:
+		return w.hash8(uint8(v.Uint()))
+
+	case reflect.Int16:
+		return w.hash16(uint16(v.Int()))
+	case reflect.Uint16:
+		return w.hash16(uint16(v.Uint()))
+
+	case reflect.Int32:
+		return w.hash32(uint32(v.Int()))
+	case reflect.Uint32:
+		return w.hash32(uint32(v.Uint()))
+	case reflect.Float32:
+		return w.hash32(math.Float32bits(float32(v.Float())))
+
+	case reflect.Int, reflect.Int64:
+		return w.hash64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return w.hash64(v.Uint())
+	case reflect.Float64:
+		return w.hash64(math.Float64bits(v.Float()))
+	case reflect.Complex64:
+		// The memory layout of a complex64: the real part, then the
+		// imaginary part
+		c := v.Complex()
+		return w.hash64(uint64(math.Float32bits(float32(real(c)))) | uint64(math.Float32bits(float32(imag(c))))<<32)
 
 	default:
-		h.Reset()
-		_ = binary.Write(h, binary.LittleEndian, i)
-		return h.Sum64()
+		panic(fmt.Sprintf("hashstructure: %s is not a number", v.Kind()))
 	}
 }
 
-func hash8(h hash.Hash64, i uint8) uint64 {
-	h.Reset()
-	_, _ = h.Write([]byte{i})
-	return h.Sum64()
+// hashNumberAt hashes the bool or number of kind k stored at p like
+// hashNumber, for UnsafeFastPath.
+func (w *walker) hashNumberAt(k reflect.Kind, p unsafe.Pointer) uint64 {
+	switch k {
+	case reflect.Bool:
+		if *(*bool)(p) {
+			return w.hash8(1)
+		}
+		return w.hash8(0)
+	case reflect.Int8, reflect.Uint8:
+		return w.hash8(*(*uint8)(p))
+
+	case reflect.Int16, reflect.Uint16:
+		return w.hash16(*(*uint16)(p))
+
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return w.hash32(*(*uint32)(p))
+
+	case reflect.Int:
+		return w.hash64(uint64(*(*int)(p)))
+	case reflect.Uint:
+		return w.hash64(uint64(*(*uint)(p)))
+	case reflect.Uintptr:
+		return w.hash64(uint64(*(*uintptr)(p)))
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return w.hash64(*(*uint64)(p))
+	case reflect.Complex64:
+		c := *(*complex64)(p)
+		return w.hash64(uint64(math.Float32bits(real(c))) | uint64(math.Float32bits(imag(c)))<<32)
+
+	default:
+		panic(fmt.Sprintf("hashstructure: %s is not a number", k))
+	}
 }
 
-func hash16(h hash.Hash64, i uint16) uint64 {
-	h.Reset()
-	_, _ = h.Write([]byte{byte(i), byte(i >> 8)})
-	return h.Sum64()
+// hashRoundedFloat returns the hash of f rounded to an int64, for
+// RoundFloatsToInt.
+func (w *walker) hashRoundedFloat(f float64) (uint64, error) {
+	r := math.Round(f)
+	// -2^63 is the smallest int64, and 2^63 is one more than the largest
+	if math.IsNaN(r) || r < -(1<<63) || r >= 1<<63 {
+		return 0, &ErrUnroundable{Path: strings.TrimPrefix(strings.Join(w.path, ""), "."), Value: f}
+	}
+
+	i := int64(r)
+	if w.canonical {
+		w.text = "int64(" + strconv.FormatInt(i, 10) + ")"
+	}
+	return w.hash64(uint64(i)), nil
 }
 
-func hash32(h hash.Hash64, i uint32) uint64 {
-	h.Reset()
-	_, _ = h.Write([]byte{byte(i), byte(i >> 8), byte(i >> 16), byte(i >> 24)})
-	return h.Sum64()
+// coerceInteger returns the canonical integer value of v if v is an
+// integer or a float holding an integral value, as its magnitude and
+// whether it is negative. That way every value from math.MinInt64 to
+// math.MaxUint64 has its own encoding.
+func coerceInteger(v reflect.Value) (uint64, bool, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if i < 0 {
+			// The negation of math.MinInt64 overflows back to itself,
+			// which is still its magnitude as a uint64
+			return uint64(-i), true, true
+		}
+		return uint64(i), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), false, true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= 1<<64 {
+			return 0, false, false
+		}
+		if f < 0 {
+			return uint64(-f), true, true
+		}
+		return uint64(f), false, true
+	default:
+		return 0, false, false
+	}
+}
+
+// canonicalInteger returns the canonical text of an integer coerced by
+// coerceInteger.
+func canonicalInteger(i uint64, neg bool) string {
+	switch {
+	case neg:
+		return "int64(-" + strconv.FormatUint(i, 10) + ")"
+	case i > math.MaxInt64:
+		return "uint64(" + strconv.FormatUint(i, 10) + ")"
+	}
+	return "int64(" + strconv.FormatUint(i, 10) + ")"
+}
+
+// hashNegative hashes the magnitude i of a negative integer coerced by
+// coerceInteger. It is prefixed with a sign byte, so it doesn't collide
+// with the positive integer of the same bits.
+func (w *walker) hashNegative(i uint64) uint64 {
+	w.buf[0] = '-'
+	w.order.PutUint64(w.buf[1:9], i)
+	w.h.Reset()
+	_, _ = w.h.Write(w.buf[:9])
+	return w.h.Sum64()
 }
 
-func hash64(h hash.Hash64, i uint64) uint64 {
-	h.Reset()
-	_, _ = h.Write([]byte{byte(i), byte(i >> 8), byte(i >> 16), byte(i >> 24), byte(i >> 32), byte(i >> 40), byte(i >> 48), byte(i >> 56)})
-	return h.Sum64()
+func (w *walker) hash8(i uint8) uint64 {
+	w.buf[0] = i
+	w.h.Reset()
+	_, _ = w.h.Write(w.buf[:1])
+	return w.h.Sum64()
 }
 
+func (w *walker) hash16(i uint16) uint64 {
+	w.order.PutUint16(w.buf[:2], i)
+	w.h.Reset()
+	_, _ = w.h.Write(w.buf[:2])
+	return w.h.Sum64()
+}
+
+func (w *walker) hash32(i uint32) uint64 {
+	w.order.PutUint32(w.buf[:4], i)
+	w.h.Reset()
+	_, _ = w.h.Write(w.buf[:4])
+	return w.h.Sum64()
+}
+
+func (w *walker) hash64(i uint64) uint64 {
+	w.order.PutUint64(w.buf[:8], i)
+	w.h.Reset()
+	_, _ = w.h.Write(w.buf[:8])
+	return w.h.Sum64()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var reflectTypeType = reflect.TypeOf((*reflect.Type)(nil)).Elem()
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+var syncMapType = reflect.TypeOf((*sync.Map)(nil)).Elem()
+
+// startDetectingCyclesAfter is the nesting depth at which the walk starts
+// detecting cycles.
+const startDetectingCyclesAfter = 1000
+
+// cycleSentinel is hashed in place of a value that is already being
+// visited, which means the value is cyclic.
+const cycleSentinel uint64 = 0xc2b2ae3d27d4eb4f
+
+// fieldSaltSeed is the seed of the salts of FieldSalting.
+const fieldSaltSeed uint64 = 0x85ebca6b3c6ef372
+
+// nilSentinel is hashed in place of nil values that must not collide with
+// the zero value of a type.
+const nilSentinel uint64 = 0x9e3779b97f4a7c15
+
 // visitFlag is used as a bitmask for affecting visit behavior
 type visitFlag uint
 
 const (
-	_            visitFlag = iota
-	visitFlagSet           = iota << 1
+	_               visitFlag = iota
+	visitFlagSet              = iota << 1
+	visitFlagRoot             = iota << 1
+	visitFlagName             = 1 << iota
+	visitFlagRevSet           = 1 << iota
 )
@@ -1,9 +1,26 @@
 package hashstructure
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"image"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func TestHash_identity(t *testing.T) {
@@ -273,6 +290,103 @@ func TestHash_equalIgnore(t *testing.T) {
 	}
 }
 
+func TestHash_ignoreLikeRemoved(t *testing.T) {
+	// An ignored field hashes exactly as if the struct didn't have it, so
+	// a field can be deprecated without changing existing hash values.
+	type Inner struct {
+		A int
+		B string `hash:"ignore"`
+	}
+
+	var ignored, removed, renamed interface{}
+	{
+		type Test struct {
+			Name    string
+			Old     string `hash:"ignore"`
+			Dropped []int  `hash:"-"`
+			Inner   Inner
+			Only    struct {
+				Gone int `hash:"ignore"`
+			}
+			secret string `hash:"-"`
+		}
+		ignored = Test{Name: "foo", Old: "bar", Dropped: []int{1}, Inner: Inner{A: 1, B: "b"}, secret: "s"}
+	}
+	{
+		type Inner struct {
+			A int
+		}
+		type Test struct {
+			Name  string
+			Inner Inner
+			Only  struct{}
+		}
+		removed = Test{Name: "foo", Inner: Inner{A: 1}}
+	}
+	{
+		type Other struct {
+			Name  string
+			Inner struct{ A int }
+			Only  struct{}
+		}
+		renamed = Other{Name: "foo", Inner: struct{ A int }{A: 1}}
+	}
+
+	noNames := func(reflect.Type) string { return "" }
+	order := func(reflect.Type) []string { return []string{"Old", "Inner", "Name"} }
+	cases := []struct {
+		Two  interface{}
+		Opts *HashOptions
+	}{
+		{removed, nil},
+		{removed, &HashOptions{OrderedFields: true}},
+		{removed, &HashOptions{FieldOrderFunc: order}},
+		{removed, &HashOptions{NonZeroEmptyStructs: true}},
+		{removed, &HashOptions{IncludeUnexported: true}},
+		{removed, &HashOptions{CollectErrors: true}},
+		{renamed, &HashOptions{StructNameFunc: noNames}},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(ignored, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", ignored, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+		if one != two {
+			t.Fatalf("bad, expected equal hashes with %#v\n\n%#v\n\n%#v", tc.Opts, ignored, tc.Two)
+		}
+
+		oneText, err := Canonical(ignored, tc.Opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		twoText, err := Canonical(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if oneText != twoText {
+			t.Fatalf("bad, expected equal canonical text:\n\n%s\n\n%s", oneText, twoText)
+		}
+	}
+
+	// Without matching names the struct names still tell them apart
+	one, err := Hash(ignored, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(renamed, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one == two {
+		t.Fatal("bad: struct names not hashed")
+	}
+}
+
 func TestHash_stringTagError(t *testing.T) {
 	type Test1 struct {
 		Name        string
@@ -408,6 +522,22 @@ func TestHash_equalSet(t *testing.T) {
 		Friends []string `hash:"set"`
 	}
 
+	type TestPoints struct {
+		Points []testPoint `hash:"set"`
+	}
+
+	type User struct {
+		Name string
+		Age  int
+	}
+	type TestUsers struct {
+		Users []*User `hash:"set"`
+	}
+
+	// Distinct pointers to equal users
+	alice, alice2 := &User{Name: "alice", Age: 30}, &User{Name: "alice", Age: 30}
+	bob := &User{Name: "bob", Age: 40}
+
 	cases := []struct {
 		One, Two interface{}
 		Match    bool
@@ -423,6 +553,119 @@ func TestHash_equalSet(t *testing.T) {
 			Test{Name: "foo", Friends: []string{"foo", "bar"}},
 			true,
 		},
+
+		{
+			Test{Name: "foo", Friends: []string{"foo", "foo"}},
+			Test{Name: "foo", Friends: []string{}},
+			false,
+		},
+
+		{
+			TestPoints{Points: []testPoint{{1, 1}, {1, 1}}},
+			TestPoints{Points: []testPoint{{1, 1}}},
+			false,
+		},
+
+		{
+			TestPoints{Points: []testPoint{{1, 1}, {1, 1}, {2, 3}}},
+			TestPoints{Points: []testPoint{{2, 3}, {1, 1}, {1, 1}}},
+			true,
+		},
+
+		{
+			TestPoints{Points: []testPoint{{1, 1}, {1, 1}, {2, 3}}},
+			TestPoints{Points: []testPoint{{2, 3}, {2, 3}, {1, 1}}},
+			false,
+		},
+
+		// Pointers are compared by what they point to, and each counts
+		{
+			TestUsers{Users: []*User{alice, alice2}},
+			TestUsers{Users: []*User{alice}},
+			false,
+		},
+
+		{
+			TestUsers{Users: []*User{alice, alice2}},
+			TestUsers{Users: []*User{alice, alice}},
+			true,
+		},
+
+		{
+			TestUsers{Users: []*User{alice, bob, alice2}},
+			TestUsers{Users: []*User{bob, alice2, alice}},
+			true,
+		},
+
+		{
+			TestUsers{Users: []*User{alice, bob}},
+			TestUsers{Users: []*User{bob, bob}},
+			false,
+		},
+
+		{
+			TestUsers{Users: []*User{alice, nil}},
+			TestUsers{Users: []*User{nil, alice2}},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_revSet(t *testing.T) {
+	type Test struct {
+		Path []int `hash:"revset"`
+	}
+	type Nested struct {
+		Paths [][]string `hash:"revset"`
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{Test{Path: []int{1, 2, 3}}, Test{Path: []int{3, 2, 1}}, true},
+		{Test{Path: []int{1, 2, 3}}, Test{Path: []int{2, 1, 3}}, false},
+		{Test{Path: []int{1, 2, 3}}, Test{Path: []int{1, 3, 2}}, false},
+		{Test{Path: []int{1, 2, 1}}, Test{Path: []int{1, 2, 1}}, true},
+		{Test{Path: []int{1, 1, 2}}, Test{Path: []int{2, 1, 1}}, true},
+		{Test{Path: []int{1, 2}}, Test{Path: []int{1, 2, 2}}, false},
+		{Test{Path: []int{}}, Test{Path: []int{1}}, false},
+
+		// Only the outer slice is reversed
+		{
+			Nested{Paths: [][]string{{"a", "b"}, {"c"}}},
+			Nested{Paths: [][]string{{"c"}, {"a", "b"}}},
+			true,
+		},
+		{
+			Nested{Paths: [][]string{{"a", "b"}, {"c"}}},
+			Nested{Paths: [][]string{{"c"}, {"b", "a"}}},
+			false,
+		},
+
+		// Without the tag, order matters
+		{[]int{1, 2, 3}, []int{3, 2, 1}, false},
 	}
 
 	for _, tc := range cases {
@@ -444,6 +687,19 @@ func TestHash_equalSet(t *testing.T) {
 		if (one == two) != tc.Match {
 			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
 		}
+
+		// The canonical text is as equal as the hash
+		oneText, err := Canonical(tc.One, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		twoText, err := Canonical(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if (oneText == twoText) != tc.Match {
+			t.Fatalf("bad canonical text, expected: %#v\n\n%s\n\n%s", tc.Match, oneText, twoText)
+		}
 	}
 }
 
@@ -563,3 +819,5820 @@ func (t testIncludableMap) HashIncludeMap(field string, k, v interface{}) (bool,
 
 	return true, nil
 }
+
+func TestHash_includableMapSet(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			testIncludableMapSet{Maps: []map[string]int{{"foo": 1, "ignore": 2}, {"bar": 3}}},
+			testIncludableMapSet{Maps: []map[string]int{{"bar": 3}, {"foo": 1}}},
+			nil,
+			true,
+		},
+		{
+			testIncludableMapSet{Maps: []map[string]int{{"foo": 1, "ignore": 2}, {"bar": 3}}},
+			testIncludableMapSet{Maps: []map[string]int{{"bar": 3}, {"foo": 1}}},
+			&HashOptions{Iterative: true},
+			true,
+		},
+		{
+			testIncludableMapSet{Maps: []map[string]int{{"foo": 1, "ignore": 2}}},
+			testIncludableMapSet{Maps: []map[string]int{{"foo": 2}}},
+			nil,
+			false,
+		},
+
+		// Elements of slices that aren't sets aren't filtered
+		{
+			testIncludableMapSet{List: []map[string]int{{"foo": 1, "ignore": 2}}},
+			testIncludableMapSet{List: []map[string]int{{"foo": 1}}},
+			nil,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+type testIncludableMapSet struct {
+	Maps []map[string]int `hash:"set"`
+	List []map[string]int
+}
+
+func (t testIncludableMapSet) HashIncludeMap(field string, k, v interface{}) (bool, error) {
+	return k != "ignore", nil
+}
+
+func TestHash_onlyFields(t *testing.T) {
+	type Test struct {
+		ID      string
+		Region  string
+		Comment string
+		Tags    []string
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			Test{ID: "foo", Region: "us", Comment: "a", Tags: []string{"x"}},
+			Test{ID: "foo", Region: "us", Comment: "b", Tags: []string{"y"}},
+			true,
+		},
+
+		{
+			Test{ID: "foo", Region: "us"},
+			Test{ID: "foo", Region: "eu"},
+			false,
+		},
+
+		{
+			&Test{ID: "foo", Region: "us", Comment: "a"},
+			Test{ID: "foo", Region: "us", Comment: "b"},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{OnlyFields: []string{"ID", "Region"}}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_onlyFieldsError(t *testing.T) {
+	type Test struct {
+		ID string
+	}
+
+	cases := []struct {
+		Test       interface{}
+		OnlyFields []string
+	}{
+		{
+			Test{ID: "foo"},
+			[]string{"ID", "Missing"},
+		},
+		{
+			"foo",
+			[]string{"ID"},
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := Hash(tc.Test, &HashOptions{OnlyFields: tc.OnlyFields})
+		if err == nil {
+			t.Fatalf("expected error for %#v with %v", tc.Test, tc.OnlyFields)
+		}
+	}
+}
+
+func TestHash_onlyFieldsFlattenEmbedded(t *testing.T) {
+	type Base struct {
+		ID      string
+		Comment string
+	}
+	type Test struct {
+		Base
+		Region string
+		Tags   []string
+	}
+
+	cases := []struct {
+		One, Two   interface{}
+		OnlyFields []string
+		Match      bool
+	}{
+		{
+			Test{Base: Base{ID: "foo", Comment: "a"}, Region: "us", Tags: []string{"x"}},
+			Test{Base: Base{ID: "foo", Comment: "b"}, Region: "us", Tags: []string{"y"}},
+			[]string{"ID", "Region"},
+			true,
+		},
+		{
+			Test{Base: Base{ID: "foo"}, Region: "us"},
+			Test{Base: Base{ID: "bar"}, Region: "us"},
+			[]string{"ID", "Region"},
+			false,
+		},
+		{
+			Test{Base: Base{ID: "foo"}, Region: "us"},
+			Test{Base: Base{ID: "foo"}, Region: "eu"},
+			[]string{"ID"},
+			true,
+		},
+
+		// Listing the embedded struct hashes all its fields
+		{
+			Test{Base: Base{ID: "foo", Comment: "a"}},
+			Test{Base: Base{ID: "foo", Comment: "b"}},
+			[]string{"Base"},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{FlattenEmbedded: true, OnlyFields: tc.OnlyFields}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Promoted fields are only valid names when they are flattened
+	if _, err := Hash(Test{}, &HashOptions{OnlyFields: []string{"ID"}}); err == nil {
+		t.Fatal("expected error for a promoted field without FlattenEmbedded")
+	}
+}
+
+func TestHash_numericCoercion(t *testing.T) {
+	cases := []struct {
+		One, Two        interface{}
+		NumericCoercion bool
+		Match           bool
+	}{
+		{
+			map[string]interface{}{"n": 1},
+			map[string]interface{}{"n": float64(1)},
+			true,
+			true,
+		},
+		{
+			map[string]interface{}{"n": 1},
+			map[string]interface{}{"n": float64(1)},
+			false,
+			false,
+		},
+		{
+			struct{ N interface{} }{int32(-7)},
+			struct{ N interface{} }{float32(-7)},
+			true,
+			true,
+		},
+		{
+			map[string]interface{}{"n": 1},
+			map[string]interface{}{"n": 1.5},
+			true,
+			false,
+		},
+
+		// Negative integers don't collide with large unsigned ones
+		{int(-1), uint64(math.MaxUint64), true, false},
+		{int64(math.MinInt64), uint64(1 << 63), true, false},
+		{int64(math.MinInt64), float64(math.MinInt64), true, true},
+
+		// Floats are coerced up to the largest unsigned integers
+		{uint64(1 << 63), float64(1 << 63), true, true},
+		{uint64(1 << 63), uint64(1<<63 + 1), true, false},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, &HashOptions{NumericCoercion: tc.NumericCoercion})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, &HashOptions{NumericCoercion: tc.NumericCoercion})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_selfIncludable(t *testing.T) {
+	type Test struct {
+		Name  string
+		Field testSelfIncludable
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			[]interface{}{"foo", testSelfIncludable{Value: "bar", Exclude: true}},
+			[]interface{}{"foo"},
+			true,
+		},
+
+		{
+			[]interface{}{"foo", testSelfIncludable{Value: "bar"}},
+			[]interface{}{"foo"},
+			false,
+		},
+
+		{
+			[2]interface{}{"foo", testSelfIncludable{Value: "bar", Exclude: true}},
+			[2]interface{}{"foo", testSelfIncludable{Value: "baz", Exclude: true}},
+			true,
+		},
+
+		{
+			map[string]interface{}{"foo": "bar", "ignore": testSelfIncludable{Exclude: true}},
+			map[string]interface{}{"foo": "bar"},
+			true,
+		},
+
+		{
+			Test{Name: "foo", Field: testSelfIncludable{Value: "bar", Exclude: true}},
+			Test{Name: "foo", Field: testSelfIncludable{Value: "baz", Exclude: true}},
+			true,
+		},
+
+		{
+			Test{Name: "foo", Field: testSelfIncludable{Value: "bar"}},
+			Test{Name: "foo", Field: testSelfIncludable{Value: "baz"}},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+type testSelfIncludable struct {
+	Value   string
+	Exclude bool
+}
+
+func (t testSelfIncludable) HashSelfInclude() (bool, error) {
+	return !t.Exclude, nil
+}
+
+func TestHash_method(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			testMethod{First: "foo", Last: "bar", Key: "ignored"},
+			testMethod{First: "foo", Last: "bar"},
+			true,
+		},
+
+		{
+			testMethod{First: "foo", Last: "bar"},
+			testMethod{First: "foo", Last: "baz"},
+			false,
+		},
+
+		{
+			&testMethodPtr{ID: 1, Sum: 2},
+			&testMethodPtr{ID: 1, Sum: 3},
+			true,
+		},
+
+		// Pointer receivers are found on structs passed by value
+		{
+			testMethodPtr{ID: 1, Sum: 2},
+			testMethodPtr{ID: 1, Sum: 3},
+			true,
+		},
+
+		{
+			testMethodPtr{ID: 1},
+			testMethodPtr{ID: 2},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_methodTagError(t *testing.T) {
+	type Test1 struct {
+		Name string `hash:"method:Missing"`
+	}
+
+	cases := []struct {
+		Test  interface{}
+		Field string
+	}{
+		{
+			Test1{Name: "foo"},
+			"Name",
+		},
+		{
+			testMethodArgs{Name: "foo"},
+			"Name",
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := Hash(tc.Test, nil)
+		eim, ok := err.(*ErrInvalidMethod)
+		if !ok {
+			t.Fatalf("expected ErrInvalidMethod for %#v: got %v", tc.Test, err)
+		}
+		if eim.Field != tc.Field {
+			t.Fatalf("did not get expected field %#v: got %s wanted %s", tc.Test, eim.Field, tc.Field)
+		}
+	}
+}
+
+type testMethod struct {
+	First string `hash:"ignore"`
+	Last  string `hash:"ignore"`
+	Key   string `hash:"method:FullName"`
+}
+
+func (t testMethod) FullName() string {
+	return t.First + " " + t.Last
+}
+
+type testMethodPtr struct {
+	ID  int
+	Sum int `hash:"method:Computed"`
+}
+
+func (t *testMethodPtr) Computed() (int, error) {
+	return t.ID * 10, nil
+}
+
+type testMethodArgs struct {
+	Name string `hash:"method:Args"`
+}
+
+func (t testMethodArgs) Args(s string) string {
+	return s
+}
+
+func TestHash_skipNilPointers(t *testing.T) {
+	one := 1
+
+	cases := []struct {
+		One, Two        interface{}
+		SkipNilPointers bool
+		ZeroNil         bool
+		Match           bool
+	}{
+		{
+			struct {
+				Name  string
+				Count *int
+			}{Name: "foo"},
+			struct{ Name string }{Name: "foo"},
+			true,
+			false,
+			true,
+		},
+		{
+			struct {
+				Name  string
+				Count *int
+			}{Name: "foo"},
+			struct{ Name string }{Name: "foo"},
+			false,
+			false,
+			false,
+		},
+		{
+			struct {
+				Name  string
+				Count *int
+			}{Name: "foo"},
+			struct{ Name string }{Name: "foo"},
+			true,
+			true,
+			true,
+		},
+		{
+			struct {
+				Name  string
+				Count *int
+			}{Name: "foo", Count: &one},
+			struct{ Name string }{Name: "foo"},
+			true,
+			false,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{SkipNilPointers: tc.SkipNilPointers, ZeroNil: tc.ZeroNil}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_genericInstantiation(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			testBox[int]{},
+			testBox[string]{},
+			false,
+		},
+
+		{
+			testBox[int]{},
+			testBox[int]{},
+			true,
+		},
+
+		{
+			testBox[testBox[int]]{},
+			testBox[testBox[string]]{},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+type testBox[T any] struct {
+	Value *T `hash:"ignore"`
+}
+
+func TestHash_interfaceHandlers(t *testing.T) {
+	type Test struct {
+		Name string
+		Info fs.FileInfo
+	}
+
+	modTime := time.Date(2020, 2, 14, 0, 0, 0, 0, time.UTC)
+	handlers := []InterfaceHandler{
+		{
+			Iface: reflect.TypeOf((*fs.FileInfo)(nil)).Elem(),
+			Fn: func(v reflect.Value) (uint64, error) {
+				fi := v.Interface().(fs.FileInfo)
+				return Hash([]interface{}{fi.Name(), fi.Size(), fi.ModTime().Unix(), uint32(fi.Mode())}, nil)
+			},
+		},
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			&testFileInfo{name: "foo", size: 1, modTime: modTime, sys: 1},
+			&testFileInfo{name: "foo", size: 1, modTime: modTime, sys: 2},
+			true,
+		},
+
+		{
+			Test{Name: "dir", Info: &testFileInfo{name: "foo", size: 1, modTime: modTime, sys: 1}},
+			Test{Name: "dir", Info: &testFileInfo{name: "foo", size: 1, modTime: modTime, sys: 2}},
+			true,
+		},
+
+		{
+			[]fs.FileInfo{&testFileInfo{name: "foo", size: 1, modTime: modTime}},
+			[]fs.FileInfo{&testFileInfo{name: "foo", size: 2, modTime: modTime}},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, &HashOptions{InterfaceHandlers: handlers})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, &HashOptions{InterfaceHandlers: handlers})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+type testFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	sys     int
+}
+
+func (t *testFileInfo) Name() string       { return t.name }
+func (t *testFileInfo) Size() int64        { return t.size }
+func (t *testFileInfo) Mode() fs.FileMode  { return 0644 }
+func (t *testFileInfo) ModTime() time.Time { return t.modTime }
+func (t *testFileInfo) IsDir() bool        { return false }
+func (t *testFileInfo) Sys() interface{}   { return t.sys }
+
+func TestVerify(t *testing.T) {
+	cases := []struct {
+		Value interface{}
+		Err   bool
+	}{
+		{
+			map[string]interface{}{"foo": []string{"bar", "baz"}, "bar": 42},
+			false,
+		},
+
+		{
+			&testNonDeterministic{Name: "foo"},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		err := Verify(tc.Value, nil, 10)
+		if (err != nil) != tc.Err {
+			t.Fatalf("bad error for %#v, expected error: %v, got: %v", tc.Value, tc.Err, err)
+		}
+	}
+}
+
+type testNonDeterministic struct {
+	Name  string
+	Count int `hash:"method:Next"`
+}
+
+func (t *testNonDeterministic) Next() int {
+	t.Count++
+	return t.Count
+}
+
+type testPoint struct {
+	X, Y int
+}
+
+func TestHash_emptyString(t *testing.T) {
+	type Test struct {
+		Value interface{}
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			"",
+			[]byte{},
+			false,
+		},
+
+		{
+			"",
+			(*int)(nil),
+			false,
+		},
+
+		{
+			Test{Value: ""},
+			Test{Value: []byte{}},
+			false,
+		},
+
+		{
+			Test{Value: ""},
+			Test{Value: nil},
+			false,
+		},
+
+		{
+			Test{Value: ""},
+			Test{Value: ""},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_embeddedInterface(t *testing.T) {
+	type Test struct {
+		fmt.Stringer
+		Name string
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Stringer: testStringer(0)},
+			false,
+		},
+
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo"},
+			true,
+		},
+
+		{
+			Test{Name: "foo", Stringer: testStringer(1)},
+			Test{Name: "foo", Stringer: testStringer(1)},
+			true,
+		},
+
+		{
+			Test{Name: "foo", Stringer: testStringer(1)},
+			Test{Name: "foo", Stringer: testStringer(2)},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_embeddedPointer(t *testing.T) {
+	type Base struct {
+		A int
+	}
+	type Test struct {
+		*Base
+		Name string
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Base: &Base{}},
+			nil,
+			false,
+		},
+
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo"},
+			nil,
+			true,
+		},
+
+		{
+			Test{Name: "foo", Base: &Base{A: 1}},
+			Test{Name: "foo", Base: &Base{A: 1}},
+			nil,
+			true,
+		},
+
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Base: &Base{}},
+			&HashOptions{ZeroNil: true},
+			true,
+		},
+
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Base: &Base{}},
+			&HashOptions{SkipNilPointers: true},
+			false,
+		},
+
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo"},
+			&HashOptions{SkipNilPointers: true},
+			true,
+		},
+
+		{
+			Test{Name: "foo", Base: &Base{A: 1}},
+			Test{Name: "foo", Base: &Base{A: 2}},
+			&HashOptions{FlattenEmbedded: true},
+			false,
+		},
+
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Base: &Base{}},
+			&HashOptions{FlattenEmbedded: true},
+			false,
+		},
+
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Base: &Base{}},
+			&HashOptions{FlattenEmbedded: true, ZeroNil: true},
+			true,
+		},
+
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Base: &Base{}},
+			&HashOptions{FlattenEmbedded: true, SkipNilPointers: true},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two, tc.Opts)
+		}
+	}
+}
+
+func TestHash_flattenEmbedded(t *testing.T) {
+	type Base struct {
+		A int
+	}
+
+	var embedded, declared interface{}
+	{
+		type Test struct {
+			Base
+			Name string
+		}
+		embedded = Test{Base: Base{A: 1}, Name: "foo"}
+	}
+	{
+		type Test struct {
+			A    int
+			Name string
+		}
+		declared = Test{A: 1, Name: "foo"}
+	}
+
+	opts := &HashOptions{FlattenEmbedded: true}
+	one, err := Hash(embedded, opts)
+	if err != nil {
+		t.Fatalf("Failed to hash %#v: %s", embedded, err)
+	}
+	two, err := Hash(declared, opts)
+	if err != nil {
+		t.Fatalf("Failed to hash %#v: %s", declared, err)
+	}
+	if one != two {
+		t.Fatalf("flattened hash %d does not match declared hash %d", one, two)
+	}
+
+	three, err := Hash(embedded, nil)
+	if err != nil {
+		t.Fatalf("Failed to hash %#v: %s", embedded, err)
+	}
+	if three == two {
+		t.Fatal("embedded struct should not be flattened by default")
+	}
+
+	// Promoted fields follow Go's rules at any depth: a field hides the
+	// fields of the same name nested deeper, and fields of the same name at
+	// the same depth hide each other.
+	type Inner struct {
+		B    int
+		Tags map[string]int
+	}
+	type Middle struct {
+		Inner
+		A int
+		B string
+	}
+	type Left struct {
+		ID int
+	}
+	type Right struct {
+		ID int
+	}
+
+	cases := []struct {
+		Embedded, Inlined interface{}
+	}{
+		{
+			func() interface{} {
+				type Test struct {
+					Middle
+					Name string
+					A    int
+				}
+				return Test{
+					Middle: Middle{Inner: Inner{B: 1, Tags: map[string]int{"x": 1, "y": 2}}, A: 2, B: "b"},
+					Name:   "foo",
+					A:      3,
+				}
+			}(),
+			func() interface{} {
+				type Test struct {
+					Tags map[string]int
+					B    string
+					Name string
+					A    int
+				}
+				return Test{Tags: map[string]int{"y": 2, "x": 1}, B: "b", Name: "foo", A: 3}
+			}(),
+		},
+		{
+			func() interface{} {
+				type Test struct {
+					*Middle
+					Name string
+				}
+				return Test{Middle: &Middle{Inner: Inner{B: 1}, A: 2, B: "b"}, Name: "foo"}
+			}(),
+			func() interface{} {
+				type Test struct {
+					Tags map[string]int
+					A    int
+					B    string
+					Name string
+				}
+				return Test{A: 2, B: "b", Name: "foo"}
+			}(),
+		},
+		{
+			func() interface{} {
+				type Test struct {
+					Left
+					Right
+					Name string
+				}
+				return Test{Left: Left{ID: 1}, Right: Right{ID: 2}, Name: "foo"}
+			}(),
+			func() interface{} {
+				type Test struct {
+					Name string
+				}
+				return Test{Name: "foo"}
+			}(),
+		},
+	}
+
+	for _, tc := range cases {
+		for _, opts := range []*HashOptions{opts, {FlattenEmbedded: true, OrderedFields: true}} {
+			one, err := Hash(tc.Embedded, opts)
+			if err != nil {
+				t.Fatalf("Failed to hash %#v: %s", tc.Embedded, err)
+			}
+			two, err := Hash(tc.Inlined, opts)
+			if err != nil {
+				t.Fatalf("Failed to hash %#v: %s", tc.Inlined, err)
+			}
+			if one != two {
+				t.Fatalf("flattened hash of %#v does not match inlined %#v", tc.Embedded, tc.Inlined)
+			}
+		}
+	}
+}
+
+type testStringer int
+
+func (t testStringer) String() string {
+	return fmt.Sprintf("testStringer(%d)", int(t))
+}
+
+func TestHash_namedNumber(t *testing.T) {
+	type myInt int
+	type myUint8 uint8
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			myInt(1),
+			myInt(2),
+			false,
+		},
+
+		{
+			myInt(1),
+			1,
+			true,
+		},
+
+		{
+			myUint8(1),
+			uint8(1),
+			true,
+		},
+
+		{
+			time.Second,
+			time.Minute,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_collectErrors(t *testing.T) {
+	type Inner struct {
+		Broken int `hash:"string"`
+	}
+
+	type Test struct {
+		Name   string
+		First  string `hash:"string"`
+		Second int    `hash:"string"`
+		Items  []Inner
+	}
+
+	v := Test{Name: "foo", Items: []Inner{{}, {}}}
+	h, err := Hash(v, &HashOptions{CollectErrors: true})
+	efs, ok := err.(*ErrFields)
+	if !ok {
+		t.Fatalf("expected ErrFields, got: %v", err)
+	}
+
+	var paths []string
+	for _, ef := range efs.Errors {
+		if _, ok := ef.Err.(*ErrNotStringer); !ok {
+			t.Fatalf("unexpected error for %s: %s", ef.Path, ef.Err)
+		}
+		paths = append(paths, ef.Path)
+	}
+	expected := []string{"First", "Second", "Items[0].Broken", "Items[1].Broken"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("bad paths, expected: %#v got: %#v", expected, paths)
+	}
+
+	// The hash is computed over the remaining fields
+	same, _ := Hash(Test{Name: "foo", First: "bar", Second: 42, Items: []Inner{{1}, {2}}}, &HashOptions{CollectErrors: true})
+	if h == 0 || h != same {
+		t.Fatalf("expected failed fields to not affect the hash: %d, %d", h, same)
+	}
+	other, _ := Hash(Test{Name: "bar", Items: []Inner{{}, {}}}, &HashOptions{CollectErrors: true})
+	if h == other {
+		t.Fatalf("expected remaining fields to affect the hash: %d", h)
+	}
+
+	// Without the option, the first error is returned
+	if _, err := Hash(v, nil); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := err.(*ErrNotStringer); !ok {
+		t.Fatalf("expected ErrNotStringer, got: %v", err)
+	}
+}
+
+func TestHash_commonTypeCanonicalization(t *testing.T) {
+	type Test struct {
+		ID   testUUID
+		From *mail.Address
+	}
+
+	id := testUUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	addr := mail.Address{Name: "Foo Bar", Address: "foo@example.com"}
+
+	opts := &HashOptions{CommonTypeCanonicalization: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// The types hash as their canonical text
+		{id, "123e4567-e89b-12d3-a456-426614174000", opts, true},
+		{&id, "123e4567-e89b-12d3-a456-426614174000", opts, true},
+		{addr, `"Foo Bar" <foo@example.com>`, opts, true},
+		{&addr, `"Foo Bar" <foo@example.com>`, opts, true},
+		{
+			Test{ID: id, From: &addr},
+			Test{ID: id, From: &mail.Address{Name: "Foo Bar", Address: "foo@example.com"}},
+			opts,
+			true,
+		},
+		{id, testUUID{1}, opts, false},
+		{
+			[]testUUID{id},
+			[]string{"123e4567-e89b-12d3-a456-426614174000"},
+			&HashOptions{CommonTypeCanonicalization: true, Iterative: true},
+			true,
+		},
+
+		// Other arrays and structs of the same shape aren't
+		{[16]byte(id), "123e4567-e89b-12d3-a456-426614174000", opts, false},
+		{
+			struct{ Name, Address string }{"Foo Bar", "foo@example.com"},
+			`"Foo Bar" <foo@example.com>`,
+			opts,
+			false,
+		},
+
+		// Not by default
+		{id, "123e4567-e89b-12d3-a456-426614174000", nil, false},
+		{addr, `"Foo Bar" <foo@example.com>`, nil, false},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+// testUUID is shaped like the UUID types of the common uuid packages.
+type testUUID [16]byte
+
+func (u testUUID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])), nil
+}
+
+func TestHash_rejectFloats(t *testing.T) {
+	type Item struct {
+		Name  string
+		Price float64
+	}
+	type Test struct {
+		Name  string
+		Items []Item
+	}
+
+	opts := &HashOptions{RejectFloats: true}
+	cases := []struct {
+		Value interface{}
+		Opts  *HashOptions
+		Path  string
+		Kind  reflect.Kind
+	}{
+		{Item{Name: "foo", Price: 1.5}, opts, "Price", reflect.Float64},
+		{Test{Items: []Item{{Name: "foo"}}}, opts, "Items[0].Price", reflect.Float64},
+		{map[string]float32{"x": 1}, opts, "[x]", reflect.Float32},
+		{map[string]interface{}{"x": []interface{}{"a", 1.0}}, opts, "[x][1]", reflect.Float64},
+		{[]float64{1, 2}, &HashOptions{RejectFloats: true, BulkNumbers: true}, "[0]", reflect.Float64},
+		{1.5, opts, "", reflect.Float64},
+	}
+
+	for _, tc := range cases {
+		_, err := Hash(tc.Value, tc.Opts)
+		var ef *ErrFloat
+		if !errors.As(err, &ef) {
+			t.Fatalf("expected ErrFloat for %#v, got: %v", tc.Value, err)
+		}
+		if ef.Path != tc.Path || ef.Kind != tc.Kind {
+			t.Fatalf("bad, expected: %s at %q\n\n%s at %q", tc.Kind, tc.Path, ef.Kind, ef.Path)
+		}
+
+		// Without the option floats are hashed
+		if _, err := Hash(tc.Value, nil); err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Value, err)
+		}
+	}
+
+	// Values without floats hash the same
+	v := struct {
+		Name  string
+		Count int
+	}{"foo", 1}
+	one, err := Hash(v, opts)
+	if err != nil {
+		t.Fatalf("Failed to hash %#v: %s", v, err)
+	}
+	two, err := Hash(v, nil)
+	if err != nil {
+		t.Fatalf("Failed to hash %#v: %s", v, err)
+	}
+	if one != two {
+		t.Fatalf("bad: %d != %d", one, two)
+	}
+
+	// Floats hashed by PreHash aren't rejected
+	preHash := func(v reflect.Value) (uint64, bool, error) {
+		return 1, v.Kind() == reflect.Float64, nil
+	}
+	if _, err := Hash(Item{Price: 1.5}, &HashOptions{RejectFloats: true, PreHash: preHash}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestHash_roundFloatsToInt(t *testing.T) {
+	type Metric struct {
+		Name  string
+		Count float64
+	}
+
+	opts := &HashOptions{RoundFloatsToInt: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{3.0000001, 2.9999999, opts, true},
+		{3.0000001, int64(3), opts, true},
+		{float32(2.9999999), int64(3), opts, true},
+		{3.0000001, 2.9999999, nil, false},
+		{3.4, 3.6, opts, false},
+		{2.5, int64(3), opts, true},
+		{-2.5, int64(-3), opts, true},
+		{-0.4, int64(0), opts, true},
+		{Metric{"hits", 41.9999}, Metric{"hits", 42.0001}, opts, true},
+		{[]float64{1.0001, 2}, []float64{0.9999, 2.0001}, &HashOptions{RoundFloatsToInt: true, BulkNumbers: true}, true},
+		{map[string]interface{}{"count": 2.9999999}, map[string]interface{}{"count": 3.0}, opts, true},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Floats without a nearest int64 fail
+	for _, tc := range []struct {
+		Value interface{}
+		Path  string
+	}{
+		{math.NaN(), ""},
+		{math.Inf(1), ""},
+		{Metric{Count: math.Inf(-1)}, "Count"},
+		{[]float64{1, 1e19}, "[1]"},
+		{float64(1 << 63), ""},
+	} {
+		_, err := Hash(tc.Value, opts)
+		var eu *ErrUnroundable
+		if !errors.As(err, &eu) || eu.Path != tc.Path {
+			t.Fatalf("expected ErrUnroundable at %q for %#v, got: %v", tc.Path, tc.Value, err)
+		}
+	}
+
+	// The smallest int64 still rounds
+	if _, err := Hash(float64(-1<<63), opts); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// RejectFloats takes precedence
+	_, err := Hash(1.0, &HashOptions{RoundFloatsToInt: true, RejectFloats: true})
+	var ef *ErrFloat
+	if !errors.As(err, &ef) {
+		t.Fatalf("expected ErrFloat, got: %v", err)
+	}
+}
+
+func TestHash_panicOnNonDeterministic(t *testing.T) {
+	type Test struct {
+		Name  string
+		Items []interface{}
+	}
+
+	opts := &HashOptions{PanicOnNonDeterministic: true}
+	cases := []struct {
+		Value interface{}
+		Opts  *HashOptions
+		Panic string
+	}{
+		{make(chan int), opts, "chan int at the root"},
+		{Test{Items: []interface{}{"a", func() {}}}, opts, "func() at Items[1]"},
+		{map[string]*sync.Map{"x": {}}, opts, "sync.Map at [x]"},
+		{Test{Items: []interface{}{unsafe.Pointer(nil)}}, opts, "unsafe.Pointer at Items[0]"},
+
+		// Handlers hash them, so they don't panic
+		{make(chan int), &HashOptions{PanicOnNonDeterministic: true, DrainChannels: true}, ""},
+		{
+			struct {
+				Fn  func()
+				Map *sync.Map
+			}{func() {}, &sync.Map{}},
+			&HashOptions{
+				PanicOnNonDeterministic: true,
+				PreHash: func(v reflect.Value) (uint64, bool, error) {
+					return 1, v.Kind() == reflect.Func, nil
+				},
+				InterfaceHandlers: []InterfaceHandler{{
+					Iface: reflect.TypeOf((*testRanger)(nil)).Elem(),
+					Fn:    func(reflect.Value) (uint64, error) { return 2, nil },
+				}},
+			},
+			"",
+		},
+		{Test{Name: "foo"}, opts, ""},
+	}
+
+	for _, tc := range cases {
+		var recovered interface{}
+		func() {
+			defer func() { recovered = recover() }()
+			Hash(tc.Value, tc.Opts)
+		}()
+
+		if tc.Panic == "" {
+			if recovered != nil {
+				t.Fatalf("unexpected panic for %#v: %v", tc.Value, recovered)
+			}
+			continue
+		}
+		msg, ok := recovered.(string)
+		if !ok || !strings.Contains(msg, tc.Panic) {
+			t.Fatalf("bad, expected panic with %q for %#v, got: %v", tc.Panic, tc.Value, recovered)
+		}
+	}
+
+	// Without the option they don't panic
+	if _, err := Hash(&sync.Map{}, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := Hash(func() {}, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// testRanger is implemented by sync.Map.
+type testRanger interface {
+	Range(func(key, value interface{}) bool)
+}
+
+func TestHash_protoMessages(t *testing.T) {
+	cases := []struct {
+		One, Two      interface{}
+		ProtoMessages bool
+		Match         bool
+	}{
+		{
+			&testProtoMessage{Name: "foo", XXX_unrecognized: []byte("a"), XXX_sizecache: 1},
+			&testProtoMessage{Name: "foo", XXX_unrecognized: []byte("b"), XXX_sizecache: 2},
+			true,
+			true,
+		},
+		{
+			&testProtoMessage{Name: "foo", XXX_unrecognized: []byte("a"), XXX_sizecache: 1},
+			&testProtoMessage{Name: "foo", XXX_unrecognized: []byte("b"), XXX_sizecache: 2},
+			false,
+			false,
+		},
+		{
+			&testProtoMessage{Name: "foo", state: 1, sizeCache: 1},
+			&testProtoMessage{Name: "foo", state: 2, sizeCache: 2},
+			true,
+			true,
+		},
+		{
+			&testProtoMessage{Name: "foo"},
+			&testProtoMessage{Name: "bar"},
+			true,
+			false,
+		},
+		{
+			struct{ XXX_unrecognized []byte }{[]byte("a")},
+			struct{ XXX_unrecognized []byte }{[]byte("b")},
+			true,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{ProtoMessages: tc.ProtoMessages}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The internal fields are skipped even with IncludeUnexported
+	opts := &HashOptions{ProtoMessages: true, IncludeUnexported: true}
+	one, err := Hash(&testProtoMessage{Name: "foo", state: 1, sizeCache: 1}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(&testProtoMessage{Name: "foo", state: 2, sizeCache: 2}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatalf("bad: %d != %d", one, two)
+	}
+}
+
+type testProtoMessage struct {
+	state         int
+	sizeCache     int32
+	unknownFields []byte
+
+	Name string
+
+	XXX_unrecognized []byte
+	XXX_sizecache    int32
+}
+
+func (t *testProtoMessage) Reset()                    { *t = testProtoMessage{} }
+func (t *testProtoMessage) String() string            { return t.Name }
+func (t *testProtoMessage) ProtoReflect() interface{} { return t }
+
+func TestHash_orderedMapper(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			testOrderedMap{"a": 1, "b": 2, "c": 3},
+			testOrderedMap{"c": 3, "b": 2, "a": 1},
+			true,
+		},
+
+		{
+			testOrderedMap{"a": 1, "b": 2},
+			testReverseOrderedMap{"a": 1, "b": 2},
+			false,
+		},
+
+		{
+			testOrderedMap{"a": 1, "b": 2},
+			map[string]int{"a": 1, "b": 2},
+			false,
+		},
+
+		{
+			testOrderedMap{"a": 1, "b": 2},
+			testOrderedMap{"a": 2, "b": 1},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_orderedMapperError(t *testing.T) {
+	if _, err := Hash(testMissingOrderedMap{"a": 1, "b": 2}, nil); err == nil {
+		t.Fatal("expected error for key order missing a key")
+	}
+}
+
+type testOrderedMap map[string]int
+
+func (m testOrderedMap) HashKeyOrder() []interface{} {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]interface{}, len(keys))
+	for i, k := range keys {
+		result[i] = k
+	}
+	return result
+}
+
+type testReverseOrderedMap map[string]int
+
+func (m testReverseOrderedMap) HashKeyOrder() []interface{} {
+	keys := testOrderedMap(m).HashKeyOrder()
+	for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+	return keys
+}
+
+type testMissingOrderedMap map[string]int
+
+func (m testMissingOrderedMap) HashKeyOrder() []interface{} {
+	return []interface{}{"a", "a"}
+}
+
+func TestHash_normalizeTime(t *testing.T) {
+	type Test struct {
+		Name string
+		Time time.Time
+	}
+
+	utc := time.Date(2020, 2, 14, 12, 0, 0, 0, time.UTC)
+	local := utc.In(time.FixedZone("UTC+2", 2*60*60))
+	now := time.Now()
+	later := utc.Add(time.Second)
+
+	cases := []struct {
+		One, Two      interface{}
+		NormalizeTime bool
+		Match         bool
+	}{
+		{
+			Test{Name: "foo", Time: utc},
+			Test{Name: "foo", Time: local},
+			true,
+			true,
+		},
+		{
+			Test{Name: "foo", Time: utc},
+			Test{Name: "foo", Time: later},
+			true,
+			false,
+		},
+		{
+			Test{Name: "foo", Time: utc},
+			Test{Name: "foo", Time: later},
+			false,
+			true,
+		},
+		{
+			[]time.Time{utc, now},
+			[]time.Time{local, now.Round(0)},
+			true,
+			true,
+		},
+		{
+			[]time.Time{utc, later},
+			[]time.Time{later, utc},
+			true,
+			false,
+		},
+		{
+			map[string]time.Time{"foo": utc, "bar": now},
+			map[string]time.Time{"foo": local, "bar": now.Round(0)},
+			true,
+			true,
+		},
+		{
+			map[string]interface{}{"foo": &utc},
+			map[string]interface{}{"foo": later},
+			true,
+			false,
+		},
+
+		// Map keys are normalized too
+		{
+			map[time.Time]int{utc: 1, now: 2},
+			map[time.Time]int{local: 1, now.Round(0): 2},
+			true,
+			true,
+		},
+		{
+			map[time.Time]int{utc: 1},
+			map[time.Time]int{later: 1},
+			true,
+			false,
+		},
+		{
+			map[time.Time]int{utc: 1, later: 2},
+			map[time.Time]int{utc: 2, later: 1},
+			true,
+			false,
+		},
+		{
+			map[*time.Time]int{&utc: 1},
+			map[*time.Time]int{&local: 1},
+			true,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{NormalizeTime: tc.NormalizeTime}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_domain(t *testing.T) {
+	v := map[string]interface{}{"foo": "bar", "baz": []int{1, 2}}
+
+	cases := []struct {
+		One, Two string
+		Match    bool
+	}{
+		{"cache", "cache", true},
+		{"cache", "dedup", false},
+		{"cache", "", false},
+		{"", "", true},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(v, &HashOptions{Domain: tc.One})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", v, err)
+		}
+		two, err := Hash(v, &HashOptions{Domain: tc.Two})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", v, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", v)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%q\n\n%q", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// An empty domain doesn't change the hash
+	plain, err := Hash(v, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	empty, err := Hash(v, &HashOptions{Domain: ""})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if plain != empty {
+		t.Fatalf("expected empty domain to not change the hash: %d != %d", plain, empty)
+	}
+}
+
+func TestHash_version(t *testing.T) {
+	v := map[string]interface{}{"foo": "bar", "baz": []int{1, 2}}
+
+	cases := []struct {
+		One, Two *HashOptions
+		Match    bool
+	}{
+		{&HashOptions{Version: 1}, &HashOptions{Version: 1}, true},
+		{&HashOptions{Version: 1}, &HashOptions{Version: 2}, false},
+		{&HashOptions{Version: 1}, nil, false},
+		{&HashOptions{Version: 0}, nil, true},
+		{&HashOptions{Version: 1, Domain: "cache"}, &HashOptions{Version: 1, Domain: "cache"}, true},
+		{&HashOptions{Version: 1, Domain: "cache"}, &HashOptions{Version: 2, Domain: "cache"}, false},
+		{&HashOptions{Version: 1, Domain: "cache"}, &HashOptions{Domain: "cache"}, false},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(v, tc.One)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", v, err)
+		}
+		two, err := Hash(v, tc.Two)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", v, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", v)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The version applies to sets as well
+	one := NewSetHasher(&HashOptions{Version: 1})
+	two := NewSetHasher(&HashOptions{Version: 2})
+	for _, s := range []*SetHasher{one, two} {
+		if err := s.AddElement("foo"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if one.Sum64() == two.Sum64() {
+		t.Fatal("expected different versions to change set hashes")
+	}
+}
+
+func TestHash_nilElements(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		ZeroNil  bool
+		Match    bool
+	}{
+		{
+			[]*int{nil},
+			[]*int{new(int)},
+			false,
+			false,
+		},
+		{
+			[]*int{nil},
+			[]int{0},
+			false,
+			false,
+		},
+		{
+			[]*int{nil},
+			[]*string{nil},
+			false,
+			false,
+		},
+		{
+			[2]*int{nil, new(int)},
+			[2]*int{new(int), new(int)},
+			false,
+			false,
+		},
+		{
+			[]*int{nil, new(int)},
+			[]*int{nil, new(int)},
+			false,
+			true,
+		},
+		{
+			[]*int{nil},
+			[]*int{new(int)},
+			true,
+			true,
+		},
+
+		// Nil interfaces don't collide with zero values either
+		{
+			[]interface{}{nil, 1, nil},
+			[]interface{}{1},
+			false,
+			false,
+		},
+		{
+			[]interface{}{nil, 1, nil},
+			[]interface{}{nil, 1},
+			false,
+			false,
+		},
+		{
+			[]interface{}{nil, 1},
+			[]interface{}{1},
+			false,
+			false,
+		},
+		{
+			[]interface{}{nil},
+			[]interface{}{0},
+			false,
+			false,
+		},
+		{
+			[]interface{}{nil},
+			[]interface{}{0},
+			true,
+			false,
+		},
+		{
+			[]error{nil},
+			[]interface{}{nil},
+			false,
+			false,
+		},
+		{
+			[]interface{}{nil, "foo", nil},
+			[]interface{}{nil, "foo", nil},
+			false,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{ZeroNil: tc.ZeroNil}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_unicodeNormalization(t *testing.T) {
+	type Test struct {
+		Name string
+	}
+
+	precomposed := "caf\u00e9"
+	decomposed := "cafe\u0301"
+
+	cases := []struct {
+		One, Two interface{}
+		Form     NormalizationForm
+		Match    bool
+	}{
+		{
+			precomposed,
+			decomposed,
+			NormalizeNFC,
+			true,
+		},
+		{
+			precomposed,
+			decomposed,
+			NormalizeNFD,
+			true,
+		},
+		{
+			precomposed,
+			decomposed,
+			NormalizeNone,
+			false,
+		},
+		{
+			Test{Name: precomposed},
+			Test{Name: decomposed},
+			NormalizeNFC,
+			true,
+		},
+		{
+			"\ufb01",
+			"fi",
+			NormalizeNFC,
+			false,
+		},
+		{
+			"\ufb01",
+			"fi",
+			NormalizeNFKC,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{UnicodeNormalization: tc.Form}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_fieldFilter(t *testing.T) {
+	type Test struct {
+		Name       string
+		CacheValue string
+		CacheTTL   int
+	}
+
+	filter := func(structType reflect.Type, field reflect.StructField, value reflect.Value) (bool, error) {
+		return !strings.HasPrefix(field.Name, "Cache"), nil
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			Test{Name: "foo", CacheValue: "a", CacheTTL: 1},
+			Test{Name: "foo", CacheValue: "b", CacheTTL: 2},
+			true,
+		},
+
+		{
+			Test{Name: "foo"},
+			Test{Name: "bar"},
+			false,
+		},
+
+		// The filter composes with Includable
+		{
+			testIncludable{Value: "foo", Ignore: "bar"},
+			testIncludable{Value: "foo"},
+			true,
+		},
+
+		{
+			[]Test{{Name: "foo", CacheTTL: 1}},
+			[]Test{{Name: "foo", CacheTTL: 2}},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{FieldFilter: filter}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Both the filter and Includable must include a field
+	excludeValue := func(structType reflect.Type, field reflect.StructField, value reflect.Value) (bool, error) {
+		return field.Name != "Value", nil
+	}
+	one, err := Hash(testIncludable{Value: "foo", Ignore: "bar"}, &HashOptions{FieldFilter: excludeValue})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(testIncludable{Value: "baz", Ignore: "baz"}, &HashOptions{FieldFilter: excludeValue})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatalf("expected all fields to be excluded: %d != %d", one, two)
+	}
+}
+
+func TestHash_byteOrder(t *testing.T) {
+	cases := []struct {
+		Value interface{}
+		Match bool
+	}{
+		{int(42), false},
+		{uint16(42), false},
+		{int32(-1), true},
+		{float64(1.5), false},
+		{uint8(42), true},
+		{true, true},
+		{[]int{1, 2}, false},
+	}
+
+	for _, tc := range cases {
+		little, err := Hash(tc.Value, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Value, err)
+		}
+		explicit, err := Hash(tc.Value, &HashOptions{ByteOrder: binary.LittleEndian})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Value, err)
+		}
+		big, err := Hash(tc.Value, &HashOptions{ByteOrder: binary.BigEndian})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Value, err)
+		}
+
+		if little != explicit {
+			t.Fatalf("default byte order is not little-endian for %#v", tc.Value)
+		}
+		if (little == big) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v", tc.Match, tc.Value)
+		}
+	}
+
+	// The default serialization is unchanged
+	h := fnv.New64()
+	h.Write([]byte{42, 0, 0, 0, 0, 0, 0, 0})
+	expected := h.Sum64()
+	actual, err := Hash(int(42), nil)
+	if err != nil {
+		t.Fatalf("Failed to hash: %s", err)
+	}
+	if actual != expected {
+		t.Fatalf("bad: %d != %d", actual, expected)
+	}
+}
+
+func TestHash_includeInterfaceType(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// uint8('1') and "1" both hash the single byte '1'
+		{
+			[]interface{}{uint8('1')},
+			[]interface{}{"1"},
+			nil,
+			true,
+		},
+
+		{
+			[]interface{}{uint8('1')},
+			[]interface{}{"1"},
+			&HashOptions{IncludeInterfaceType: true},
+			false,
+		},
+
+		{
+			[]interface{}{true, "a"},
+			[]interface{}{uint8(1), "a"},
+			&HashOptions{IncludeInterfaceType: true},
+			false,
+		},
+
+		{
+			[]interface{}{1, "1"},
+			[]interface{}{"1", 1},
+			&HashOptions{IncludeInterfaceType: true},
+			false,
+		},
+
+		{
+			[]interface{}{1, "1"},
+			[]interface{}{1, "1"},
+			&HashOptions{IncludeInterfaceType: true},
+			true,
+		},
+
+		{
+			map[string]interface{}{"a": uint8('1')},
+			map[string]interface{}{"a": "1"},
+			&HashOptions{IncludeInterfaceType: true},
+			false,
+		},
+
+		{
+			[]interface{}{[]interface{}{uint8('1')}},
+			[]interface{}{[]interface{}{"1"}},
+			&HashOptions{IncludeInterfaceType: true, Iterative: true},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The iterative walk hashes nested interface slices the same way
+	v := []interface{}{[]interface{}{1, []interface{}{"a"}}, "b"}
+	one, err := Hash(v, &HashOptions{IncludeInterfaceType: true})
+	if err != nil {
+		t.Fatalf("Failed to hash %#v: %s", v, err)
+	}
+	two, err := Hash(v, &HashOptions{IncludeInterfaceType: true, Iterative: true})
+	if err != nil {
+		t.Fatalf("Failed to hash %#v: %s", v, err)
+	}
+	if one != two {
+		t.Fatalf("iterative hash %d does not match recursive hash %d", two, one)
+	}
+}
+
+func TestHash_orderedFields(t *testing.T) {
+	// With FieldNameFromTag, both fields named x XOR to nothing when they
+	// hold the same value.
+	type PointKey struct {
+		X int `key:"x"`
+		Y int `key:"x"`
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			map[PointKey]int{{X: 1, Y: 1}: 1},
+			map[PointKey]int{{X: 2, Y: 2}: 1},
+			&HashOptions{FieldNameFromTag: "key"},
+			true,
+		},
+
+		{
+			map[PointKey]int{{X: 1, Y: 1}: 1},
+			map[PointKey]int{{X: 2, Y: 2}: 1},
+			&HashOptions{FieldNameFromTag: "key", OrderedFields: true},
+			false,
+		},
+
+		{
+			map[PointKey]int{{X: 1, Y: 2}: 1},
+			map[PointKey]int{{X: 2, Y: 1}: 1},
+			&HashOptions{FieldNameFromTag: "key", OrderedFields: true},
+			true,
+		},
+
+		{
+			map[PointKey]int{{X: 1, Y: 2}: 1, {X: 3, Y: 3}: 2},
+			map[PointKey]int{{X: 1, Y: 2}: 1, {X: 3, Y: 3}: 2},
+			&HashOptions{FieldNameFromTag: "key", OrderedFields: true},
+			true,
+		},
+
+		{
+			testPoint{X: 1, Y: 2},
+			testPoint{X: 2, Y: 1},
+			&HashOptions{OrderedFields: true},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_preHash(t *testing.T) {
+	type Test struct {
+		Name string
+		When time.Time
+		Tags []string
+	}
+
+	// preHash hashes times by their Unix seconds only, and passes through
+	// everything else.
+	var seen []reflect.Type
+	preHash := func(v reflect.Value) (uint64, bool, error) {
+		seen = append(seen, v.Type())
+		if v.Type() != reflect.TypeOf(time.Time{}) {
+			return 0, false, nil
+		}
+		return uint64(v.Interface().(time.Time).Unix()), true, nil
+	}
+
+	now := time.Unix(1000, 0)
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			Test{Name: "foo", When: now},
+			Test{Name: "foo", When: now.Add(time.Millisecond)},
+			true,
+		},
+
+		{
+			Test{Name: "foo", When: now},
+			Test{Name: "foo", When: now.Add(time.Second)},
+			false,
+		},
+
+		{
+			Test{Name: "foo", When: now, Tags: []string{"a"}},
+			Test{Name: "foo", When: now, Tags: []string{"b"}},
+			false,
+		},
+
+		{
+			[]interface{}{now, "a"},
+			[]interface{}{now.Add(time.Millisecond), "a"},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{PreHash: preHash}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Values that aren't handled hash as if PreHash wasn't set, and their
+	// elements are passed to PreHash too.
+	seen = nil
+	v := Test{Name: "foo", Tags: []string{"a", "b"}}
+	one, err := Hash(v, &HashOptions{PreHash: preHash, Iterative: true})
+	if err != nil {
+		t.Fatalf("Failed to hash: %s", err)
+	}
+	two, err := Hash(Test{Name: "foo", Tags: []string{"a", "b"}, When: time.Time{}}, nil)
+	if err != nil {
+		t.Fatalf("Failed to hash: %s", err)
+	}
+	if one == two {
+		t.Fatal("handled time should change the hash")
+	}
+	for _, typ := range []reflect.Type{reflect.TypeOf(v), reflect.TypeOf(""), reflect.TypeOf([]string{})} {
+		found := false
+		for _, s := range seen {
+			found = found || s == typ
+		}
+		if !found {
+			t.Fatalf("PreHash not called for %s: %v", typ, seen)
+		}
+	}
+
+	// Errors are returned
+	_, err = Hash(v, &HashOptions{PreHash: func(reflect.Value) (uint64, bool, error) {
+		return 0, false, fmt.Errorf("prehash error")
+	}})
+	if err == nil || err.Error() != "prehash error" {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestHash_jsonOmitEmpty(t *testing.T) {
+	type Test struct {
+		Name    string            `json:",omitempty"`
+		Count   int               `json:",omitempty"`
+		Enabled bool              `json:",omitempty"`
+		Ratio   float64           `json:",omitempty"`
+		Ptr     *int              `json:",omitempty"`
+		Tags    []string          `json:",omitempty"`
+		Labels  map[string]string `json:",omitempty"`
+		Array   [0]int            `json:",omitempty"`
+		Point   testPoint         `json:",omitempty"`
+		Always  int               `json:""`
+		Plain   int
+	}
+
+	zero := 0
+	cases := []Test{
+		{},
+		{Name: "foo", Count: 1, Enabled: true, Ratio: 0.5},
+		{Ptr: &zero, Tags: []string{}, Labels: map[string]string{}},
+		{Tags: []string{"a"}, Labels: map[string]string{"a": "b"}},
+		{Point: testPoint{X: 1}, Always: 1, Plain: 1},
+	}
+
+	for _, tc := range cases {
+		data, err := json.Marshal(tc)
+		if err != nil {
+			t.Fatalf("Failed to marshal %#v: %s", tc, err)
+		}
+		var encoded map[string]interface{}
+		if err := json.Unmarshal(data, &encoded); err != nil {
+			t.Fatalf("Failed to unmarshal %s: %s", data, err)
+		}
+
+		// Hash only the fields encoding/json includes
+		expected, err := Hash(tc, &HashOptions{
+			FieldFilter: func(st reflect.Type, f reflect.StructField, _ reflect.Value) (bool, error) {
+				_, ok := encoded[f.Name]
+				return ok || st != reflect.TypeOf(tc), nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc, err)
+		}
+		actual, err := Hash(tc, &HashOptions{JSONOmitEmpty: true})
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc, err)
+		}
+
+		if actual != expected {
+			t.Fatalf("hash doesn't match fields encoded as %s", data)
+		}
+	}
+
+	// Fields aren't omitted by default
+	one, err := Hash(Test{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to hash: %s", err)
+	}
+	two, err := Hash(Test{}, &HashOptions{JSONOmitEmpty: true})
+	if err != nil {
+		t.Fatalf("Failed to hash: %s", err)
+	}
+	if one == two {
+		t.Fatal("empty fields should be omitted only with JSONOmitEmpty")
+	}
+}
+
+func TestHash_timeZoneSensitive(t *testing.T) {
+	type Test struct {
+		Name string
+		Time time.Time
+	}
+
+	utc := time.Date(2020, 2, 14, 9, 0, 0, 0, time.UTC)
+	plus2 := utc.In(time.FixedZone("UTC+2", 2*60*60))
+	otherName := utc.In(time.FixedZone("XYZ", 0))
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			Test{Name: "foo", Time: utc},
+			Test{Name: "foo", Time: plus2},
+			false,
+		},
+		{
+			Test{Name: "foo", Time: utc},
+			Test{Name: "foo", Time: otherName},
+			false,
+		},
+		{
+			Test{Name: "foo", Time: plus2},
+			Test{Name: "foo", Time: utc.In(time.FixedZone("UTC+2", 2*60*60))},
+			true,
+		},
+		{
+			Test{Name: "foo", Time: utc},
+			Test{Name: "foo", Time: utc.Add(time.Nanosecond)},
+			false,
+		},
+		{
+			// 9am in New York isn't 9am in UTC
+			Test{Name: "foo", Time: utc},
+			Test{Name: "foo", Time: time.Date(2020, 2, 14, 9, 0, 0, 0, time.FixedZone("EST", -5*60*60))},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &HashOptions{TimeZoneSensitive: true}
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// NormalizeTime and TimeZoneSensitive are mutually exclusive
+	opts := &HashOptions{NormalizeTime: true, TimeZoneSensitive: true}
+	if _, err := Hash(Test{}, opts); err == nil {
+		t.Fatal("should error")
+	}
+	if err := NewSetHasher(opts).AddElement(Test{}); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHash_numbersUnchanged(t *testing.T) {
+	cases := []struct {
+		Opts     *HashOptions
+		Expected uint64
+	}{
+		{nil, 247576016945792637},
+		{&HashOptions{ByteOrder: binary.BigEndian}, 10719549440541217922},
+		{&HashOptions{NumericCoercion: true}, 14547173516347698052},
+		{&HashOptions{UnsafeFastPath: true}, 247576016945792637},
+		{&HashOptions{UnsafeFastPath: true, ByteOrder: binary.BigEndian}, 10719549440541217922},
+	}
+
+	for _, tc := range cases {
+		v := testNumbersValue()
+		actual, err := Hash(v, tc.Opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if actual != tc.Expected {
+			t.Fatalf("bad: %d != %d\n\n%#v", actual, tc.Expected, tc.Opts)
+		}
+
+		// Fields of a struct behind a pointer are addressable
+		actual, err = Hash(&v, tc.Opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if actual != tc.Expected {
+			t.Fatalf("bad with pointer: %d != %d\n\n%#v", actual, tc.Expected, tc.Opts)
+		}
+	}
+}
+
+func BenchmarkHash_numbers(b *testing.B) {
+	v := testNumbersValue()
+	opts := &HashOptions{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Hash(v, opts); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func BenchmarkHash_struct(b *testing.B) {
+	type Address struct {
+		Street string
+		City   string
+		Zip    int
+	}
+	type Person struct {
+		Name      string
+		Age       int
+		Emails    []string
+		Address   *Address
+		Labels    map[string]string
+		Scores    []float64
+		Active    bool
+		Nicknames []string `hash:"set"`
+	}
+
+	v := Person{
+		Name:      "foo",
+		Age:       42,
+		Emails:    []string{"foo@example.com", "bar@example.com"},
+		Address:   &Address{Street: "1 Main St", City: "Springfield", Zip: 12345},
+		Labels:    map[string]string{"team": "core", "role": "admin"},
+		Scores:    []float64{1.5, 2.5, 3.5},
+		Active:    true,
+		Nicknames: []string{"f", "fo"},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Hash(v, nil); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func BenchmarkHash_numbersUnsafe(b *testing.B) {
+	for _, fast := range []bool{false, true} {
+		b.Run(fmt.Sprintf("fast=%t", fast), func(b *testing.B) {
+			v := testNumbersValue()
+			opts := &HashOptions{UnsafeFastPath: fast}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Hash(&v, opts); err != nil {
+					b.Fatalf("err: %s", err)
+				}
+			}
+		})
+	}
+}
+
+type testNumbers struct {
+	Bool      bool
+	Int       int
+	Int8      int8
+	Int16     int16
+	Int32     int32
+	Int64     int64
+	Uint      uint
+	Uint8     uint8
+	Uint16    uint16
+	Uint32    uint32
+	Uint64    uint64
+	Uintptr   uintptr
+	Float32   float32
+	Float64   float64
+	Complex64 complex64
+	Duration  time.Duration
+	Stringer  testStringer
+	Values    []float64
+	Counts    map[string]int32
+}
+
+func testNumbersValue() testNumbers {
+	return testNumbers{
+		Bool:      true,
+		Int:       -1234567,
+		Int8:      -12,
+		Int16:     1234,
+		Int32:     -123456,
+		Int64:     1 << 40,
+		Uint:      987654,
+		Uint8:     200,
+		Uint16:    60000,
+		Uint32:    4000000000,
+		Uint64:    1<<63 + 5,
+		Uintptr:   0xdeadbeef,
+		Float32:   3.25,
+		Float64:   -2.5e10,
+		Complex64: complex(1.5, -2.25),
+		Duration:  time.Hour,
+		Stringer:  42,
+		Values:    []float64{1, 2.5, -3.75},
+		Counts:    map[string]int32{"a": 1, "b": -2},
+	}
+}
+
+func TestHash_goStringer(t *testing.T) {
+	type Test struct {
+		Value testGoStringer `hash:"string"`
+	}
+	type Both struct {
+		Value testBothStringers `hash:"string"`
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// The string tag falls back to GoString
+		{
+			Test{Value: testGoStringer{Name: "foo", Cache: 1}},
+			Test{Value: testGoStringer{Name: "foo", Cache: 2}},
+			nil,
+			true,
+		},
+		{
+			Test{Value: testGoStringer{Name: "foo"}},
+			Test{Value: testGoStringer{Name: "bar"}},
+			nil,
+			false,
+		},
+		{
+			Test{Value: testGoStringer{Name: "foo"}},
+			struct {
+				Value string
+			}{Value: `testGoStringer{"foo"}`},
+			nil,
+			false,
+		},
+
+		// String takes precedence over GoString
+		{
+			Both{Value: testBothStringers{Name: "foo", Go: "a"}},
+			Both{Value: testBothStringers{Name: "foo", Go: "b"}},
+			nil,
+			true,
+		},
+
+		// UseGoStringer hashes any GoStringer by GoString
+		{
+			testGoStringer{Name: "foo", Cache: 1},
+			testGoStringer{Name: "foo", Cache: 2},
+			&HashOptions{UseGoStringer: true},
+			true,
+		},
+		{
+			testGoStringer{Name: "foo", Cache: 1},
+			testGoStringer{Name: "foo", Cache: 2},
+			nil,
+			false,
+		},
+		{
+			testGoStringer{Name: "foo"},
+			`testGoStringer{"foo"}`,
+			&HashOptions{UseGoStringer: true},
+			true,
+		},
+		{
+			[]*testGoStringer{{Name: "foo", Cache: 1}},
+			[]*testGoStringer{{Name: "foo", Cache: 2}},
+			&HashOptions{UseGoStringer: true, Iterative: true},
+			true,
+		},
+
+		// NormalizeTime takes precedence for times
+		{
+			time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2020, 1, 1, 1, 0, 0, 0, time.FixedZone("UTC+1", 60*60)),
+			&HashOptions{UseGoStringer: true, NormalizeTime: true},
+			true,
+		},
+		{
+			time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2020, 1, 1, 1, 0, 0, 0, time.FixedZone("UTC+1", 60*60)),
+			&HashOptions{UseGoStringer: true},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+type testGoStringer struct {
+	Name  string
+	Cache int
+}
+
+func (t testGoStringer) GoString() string {
+	return fmt.Sprintf("testGoStringer{%q}", t.Name)
+}
+
+type testBothStringers struct {
+	Name string
+	Go   string
+}
+
+func (t testBothStringers) String() string   { return t.Name }
+func (t testBothStringers) GoString() string { return t.Go }
+
+func TestHash_errorsAsString(t *testing.T) {
+	type Test struct {
+		Err error
+	}
+
+	opts := &HashOptions{ErrorsAsString: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// Errors hash as their message
+		{
+			&testError{Msg: "foo", Code: 1},
+			&testError{Msg: "foo", Code: 2},
+			opts,
+			true,
+		},
+		{
+			&testError{Msg: "foo", Code: 1},
+			&testError{Msg: "foo", Code: 2},
+			nil,
+			false,
+		},
+		{
+			[]error{errors.New("foo"), &testError{Msg: "bar"}},
+			[]error{fmt.Errorf("foo"), errors.New("bar")},
+			opts,
+			true,
+		},
+		{
+			Test{Err: errors.New("foo")},
+			Test{Err: errors.New("bar")},
+			opts,
+			false,
+		},
+
+		// Nil, empty and real errors all differ
+		{
+			[]error{nil},
+			[]error{errors.New("")},
+			opts,
+			false,
+		},
+		{
+			[]error{errors.New("")},
+			[]error{errors.New("foo")},
+			opts,
+			false,
+		},
+		{
+			[]error{nil},
+			[]error{errors.New("foo")},
+			opts,
+			false,
+		},
+		{
+			[]error{nil, errors.New("foo")},
+			[]error{nil, errors.New("foo")},
+			opts,
+			true,
+		},
+		{
+			Test{},
+			Test{Err: errors.New("")},
+			opts,
+			false,
+		},
+		{
+			map[string]error{"a": nil},
+			map[string]error{"a": errors.New("")},
+			opts,
+			false,
+		},
+
+		// A nil error doesn't hash like a nil pointer in an error
+		{
+			[]error{nil},
+			[]error{(*testError)(nil)},
+			opts,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+type testError struct {
+	Msg  string
+	Code int
+}
+
+func (e *testError) Error() string { return e.Msg }
+
+type testMultiError []string
+
+func (e testMultiError) Error() string { return strings.Join(e, "; ") }
+
+func TestHash_defaultPrototypes(t *testing.T) {
+	type Retry struct {
+		Attempts int
+		Backoff  time.Duration
+	}
+	type Test struct {
+		Name  string
+		Retry Retry
+	}
+
+	defaultRetry := Retry{Attempts: 3, Backoff: time.Second}
+	opts := &HashOptions{
+		DefaultPrototypes: map[reflect.Type]interface{}{
+			reflect.TypeOf(Retry{}): defaultRetry,
+		},
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			Test{Name: "foo", Retry: defaultRetry},
+			Test{Name: "foo", Retry: defaultRetry},
+			opts,
+			true,
+		},
+		{
+			Test{Name: "foo", Retry: defaultRetry},
+			Test{Name: "foo", Retry: Retry{Attempts: 3}},
+			opts,
+			false,
+		},
+
+		// The zero value isn't the default
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Retry: defaultRetry},
+			opts,
+			false,
+		},
+
+		// Elements and map entries equal to the prototype are omitted
+		{
+			[]interface{}{"a", defaultRetry},
+			[]interface{}{"a"},
+			opts,
+			true,
+		},
+		{
+			map[string]Retry{"a": defaultRetry, "b": {Attempts: 1}},
+			map[string]Retry{"b": {Attempts: 1}},
+			opts,
+			true,
+		},
+		{
+			[]interface{}{"a", defaultRetry},
+			[]interface{}{"a"},
+			nil,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// A field equal to the prototype is omitted, as if it was ignored
+	var ignored interface{}
+	{
+		type Test struct {
+			Name  string
+			Retry Retry `hash:"ignore"`
+		}
+		ignored = Test{Name: "foo"}
+	}
+	one, err := Hash(Test{Name: "foo", Retry: defaultRetry}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(ignored, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatalf("bad: %d != %d", one, two)
+	}
+}
+
+func TestHash_lenOnly(t *testing.T) {
+	type Test struct {
+		Name    string
+		Payload []byte            `hash:"lenonly"`
+		Body    string            `hash:"lenonly"`
+		Headers map[string]string `hash:"lenonly"`
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			Test{Name: "foo", Payload: []byte("abc")},
+			Test{Name: "foo", Payload: []byte("xyz")},
+			true,
+		},
+		{
+			Test{Name: "foo", Payload: []byte("abc")},
+			Test{Name: "foo", Payload: []byte("abcd")},
+			false,
+		},
+		{
+			Test{Name: "foo", Body: "abc", Headers: map[string]string{"a": "b"}},
+			Test{Name: "foo", Body: "xyz", Headers: map[string]string{"c": "d"}},
+			true,
+		},
+		{
+			Test{Name: "foo", Headers: map[string]string{"a": "b"}},
+			Test{Name: "foo", Headers: map[string]string{"a": "b", "c": "d"}},
+			false,
+		},
+		{
+			Test{Name: "foo", Body: "abc"},
+			Test{Name: "bar", Body: "abc"},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	type Invalid struct {
+		Count int `hash:"lenonly"`
+	}
+	if _, err := Hash(Invalid{}, nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHash_fieldOrderFunc(t *testing.T) {
+	var one, two interface{}
+	{
+		type Test struct {
+			A string
+			B string
+			C int
+		}
+		one = Test{A: "a", B: "b", C: 1}
+	}
+	{
+		type Test struct {
+			C int
+			B string
+			A string
+		}
+		two = Test{A: "a", B: "b", C: 1}
+	}
+
+	order := func(names ...string) func(reflect.Type) []string {
+		return func(t reflect.Type) []string {
+			if t.Name() != "Test" {
+				return nil
+			}
+			return names
+		}
+	}
+
+	hash := func(v interface{}, opts *HashOptions) uint64 {
+		h, err := Hash(v, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", v, err)
+		}
+		return h
+	}
+
+	// Reordering the declaration keeps the hash
+	opts := &HashOptions{FieldOrderFunc: order("A", "B", "C")}
+	if hash(one, opts) != hash(two, opts) {
+		t.Fatal("declaration order should not matter")
+	}
+
+	// The order of the function is used
+	if hash(one, opts) == hash(one, &HashOptions{FieldOrderFunc: order("B", "A", "C")}) {
+		t.Fatal("order function should change the hash")
+	}
+
+	// Unlisted fields are appended in declaration order
+	if hash(one, opts) != hash(one, &HashOptions{FieldOrderFunc: order("A", "B")}) {
+		t.Fatal("unlisted field should be appended")
+	}
+	if hash(one, &HashOptions{FieldOrderFunc: order("C")}) == hash(two, &HashOptions{FieldOrderFunc: order("C")}) {
+		t.Fatal("unlisted fields should be in declaration order")
+	}
+
+	// Types without an order are combined as usual
+	if hash(one, &HashOptions{FieldOrderFunc: order()}) != hash(one, nil) {
+		t.Fatal("nil order should hash as usual")
+	}
+	if hash(testPoint{X: 1}, opts) != hash(testPoint{X: 1}, nil) {
+		t.Fatal("types without an order should hash as usual")
+	}
+}
+
+func TestHash_panic(t *testing.T) {
+	type Test struct {
+		Name  string
+		Value testPanicker `hash:"string"`
+	}
+	cases := []struct {
+		Value  interface{}
+		Opts   *HashOptions
+		Field  string
+		Method string
+	}{
+		{Test{Name: "foo"}, nil, "Value", "String"},
+		{testPanickingMethod{}, nil, "Value", "Panic"},
+		{[]testPanicker{{}}, &HashOptions{UseGoStringer: true}, "", "GoString"},
+		{testPanickingInclude{}, nil, "Name", "HashInclude"},
+	}
+
+	for _, tc := range cases {
+		_, err := Hash(tc.Value, tc.Opts)
+		var ep *ErrPanic
+		if !errors.As(err, &ep) {
+			t.Fatalf("expected ErrPanic for %#v, got %v", tc.Value, err)
+		}
+		if ep.Field != tc.Field || ep.Method != tc.Method || ep.Value != "broken" {
+			t.Fatalf("bad: %#v", ep)
+		}
+	}
+
+	// With CollectErrors the panic is reported with its path
+	_, err := Hash([]Test{{Name: "foo"}}, &HashOptions{CollectErrors: true})
+	if err == nil || !strings.Contains(err.Error(), "[0].Value: hashstructure: String panicked while hashing Value: broken") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+type testPanicker struct{}
+
+func (testPanicker) String() string   { panic("broken") }
+func (testPanicker) GoString() string { panic("broken") }
+
+type testPanickingMethod struct {
+	Value int `hash:"method:Panic"`
+}
+
+func (testPanickingMethod) Panic() int { panic("broken") }
+
+type testPanickingInclude struct {
+	Name string
+}
+
+func (testPanickingInclude) HashInclude(string, interface{}) (bool, error) {
+	panic("broken")
+}
+
+func TestHash_mapCanonicalJSON(t *testing.T) {
+	cases := []struct {
+		Value    interface{}
+		Expected interface{}
+	}{
+		{
+			map[string]interface{}{"b": 1, "a": "x"},
+			`{"a":"x","b":1}`,
+		},
+		{
+			map[string]interface{}{
+				"z": map[string]interface{}{"y": true, "x": nil},
+				"a": []interface{}{1.5, "<&>"},
+			},
+			`{"a":[1.5,"<&>"],"z":{"x":null,"y":true}}`,
+		},
+		{
+			[]map[string]int{{"b": 2, "a": 1}},
+			[]string{`{"a":1,"b":2}`},
+		},
+	}
+
+	opts := &HashOptions{MapCanonicalJSON: true}
+	for _, tc := range cases {
+		actual, err := Hash(tc.Value, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Value, err)
+		}
+		expected, err := Hash(tc.Expected, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Expected, err)
+		}
+
+		if actual != expected {
+			t.Fatalf("hash of %#v doesn't match %#v", tc.Value, tc.Expected)
+		}
+	}
+
+	// Maps without string keys hash as usual
+	one, err := Hash(map[int]string{1: "a"}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(map[int]string{1: "a"}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatal("maps without string keys should hash as usual")
+	}
+
+	// Values that can't be encoded are an error
+	if _, err := Hash(map[string]interface{}{"a": make(chan int)}, opts); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHash_drainChannels(t *testing.T) {
+	makeChan := func(vs ...int) chan int {
+		ch := make(chan int, 5)
+		for _, v := range vs {
+			ch <- v
+		}
+		return ch
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{makeChan(1, 2, 3), makeChan(1, 2, 3), true},
+		{makeChan(1, 2, 3), makeChan(3, 2, 1), false},
+		{makeChan(1, 2), makeChan(1, 2, 3), false},
+		{makeChan(1, 2, 3), []int{1, 2, 3}, true},
+		{
+			struct{ Queue chan int }{makeChan(1)},
+			struct{ Queue chan int }{makeChan(2)},
+			false,
+		},
+	}
+
+	opts := &HashOptions{DrainChannels: true}
+	for _, tc := range cases {
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The channel is restored and hashes the same every time
+	ch := makeChan(4, 5, 6)
+	if err := Verify(ch, opts, 10); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, expected := range []int{4, 5, 6} {
+		if actual := <-ch; actual != expected {
+			t.Fatalf("bad: %d != %d", actual, expected)
+		}
+	}
+
+	// Channels can't be hashed by default
+	if _, err := Hash(makeChan(1), nil); err == nil {
+		t.Fatal("should error")
+	}
+	var recvOnly <-chan int = makeChan(1)
+	if _, err := Hash(recvOnly, opts); err == nil {
+		t.Fatal("should error")
+	}
+	closed := makeChan(1)
+	close(closed)
+	if _, err := Hash(closed, opts); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHash_structNameFunc(t *testing.T) {
+	// Point has the same name and fields as image.Point
+	type Point struct {
+		X, Y int
+	}
+
+	fullName := func(t reflect.Type) string {
+		return t.PkgPath() + "." + t.Name()
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			Point{X: 1, Y: 2},
+			image.Point{X: 1, Y: 2},
+			nil,
+			true,
+		},
+		{
+			Point{X: 1, Y: 2},
+			image.Point{X: 1, Y: 2},
+			&HashOptions{StructNameFunc: fullName},
+			false,
+		},
+		{
+			image.Point{X: 1, Y: 2},
+			image.Point{X: 1, Y: 2},
+			&HashOptions{StructNameFunc: fullName},
+			true,
+		},
+		{
+			Point{X: 1, Y: 2},
+			testPoint{X: 1, Y: 2},
+			&HashOptions{StructNameFunc: func(reflect.Type) string { return "" }},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The name is used in the canonical text too
+	text, err := Canonical(image.Point{X: 1}, &HashOptions{StructNameFunc: fullName})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.HasPrefix(text, "image.Point{") {
+		t.Fatalf("bad: %s", text)
+	}
+}
+
+func TestHash_mapIterationOrder(t *testing.T) {
+	// Go randomizes the iteration order of maps, so hashing the same map
+	// many times covers many orders.
+	m := make(map[string]interface{})
+	for i := 0; i < 100; i++ {
+		m[fmt.Sprintf("key%d", i)] = map[int]string{i: "a", i + 1: "b", i + 2: "c"}
+	}
+
+	if err := Verify(m, nil, 100); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := Verify(struct{ M map[string]interface{} }{m}, &HashOptions{OrderedFields: true}, 100); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A copy with a different insertion order hashes the same
+	c := make(map[string]interface{})
+	for i := 99; i >= 0; i-- {
+		c[fmt.Sprintf("key%d", i)] = m[fmt.Sprintf("key%d", i)]
+	}
+	one, err := Hash(m, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(c, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatalf("bad: %d != %d", one, two)
+	}
+}
+
+func TestHash_fieldNames(t *testing.T) {
+	var snake, camel, plain interface{}
+	{
+		type User struct {
+			UserID string `json:"user_id"`
+			Name   string `json:"name,omitempty"`
+			Age    int    `json:"-"`
+		}
+		snake = User{UserID: "1", Name: "foo", Age: 3}
+	}
+	{
+		type User struct {
+			ID   string `json:"userId"`
+			Name string
+			Age  int
+		}
+		camel = User{ID: "1", Name: "foo", Age: 3}
+	}
+	{
+		type User struct {
+			UserID string
+			Name   string
+			Age    int
+		}
+		plain = User{UserID: "1", Name: "foo", Age: 3}
+	}
+
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{snake, camel, nil, false},
+		{snake, camel, &HashOptions{FieldNameFromTag: "json"}, false},
+		{snake, camel, &HashOptions{FieldNameFromTag: "json", NormalizeFieldNames: normalize}, true},
+		{snake, plain, nil, true},
+		{snake, plain, &HashOptions{FieldNameFromTag: "json"}, false},
+		{snake, plain, &HashOptions{NormalizeFieldNames: normalize}, true},
+		{camel, plain, &HashOptions{NormalizeFieldNames: normalize}, false},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_fieldAliases(t *testing.T) {
+	var before, after, tagged interface{}
+	{
+		type User struct {
+			UserID string
+			Name   string
+		}
+		before = User{UserID: "1", Name: "foo"}
+	}
+	{
+		type User struct {
+			UserId string
+			Name   string
+		}
+		after = User{UserId: "1", Name: "foo"}
+	}
+	{
+		type User struct {
+			UserId string `json:"user_id"`
+			Name   string `json:"name"`
+		}
+		tagged = User{UserId: "1", Name: "foo"}
+	}
+
+	aliases := map[string]string{"UserId": "UserID"}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{before, after, nil, false},
+		{before, after, &HashOptions{FieldAliases: aliases}, true},
+		{before, before, &HashOptions{FieldAliases: aliases}, true},
+		{before, after, &HashOptions{FieldAliases: map[string]string{"Name": "UserID"}}, false},
+
+		// With FieldNameFromTag the names from the tag are aliased
+		{before, tagged, &HashOptions{FieldNameFromTag: "json", FieldAliases: aliases}, false},
+		{
+			before,
+			tagged,
+			&HashOptions{FieldNameFromTag: "json", FieldAliases: map[string]string{"user_id": "UserID", "name": "Name"}},
+			true,
+		},
+
+		// Aliases are normalized like other names
+		{
+			before,
+			after,
+			&HashOptions{FieldAliases: map[string]string{"UserId": "userid"}, NormalizeFieldNames: strings.ToLower},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The hash of an aliased field is the hash before the rename
+	one, err := Hash(before, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(after, &HashOptions{FieldAliases: aliases})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatalf("bad: %d != %d", one, two)
+	}
+}
+
+func TestHash_fieldNameHasher(t *testing.T) {
+	var upper, lower, other interface{}
+	{
+		type Test struct {
+			Name string `json:"Name"`
+		}
+		upper = Test{Name: "foo"}
+		other = Test{Name: "bar"}
+	}
+	{
+		type Test struct {
+			Name string `json:"name"`
+		}
+		lower = Test{Name: "foo"}
+	}
+
+	// Hashing the name like Hash does gives the same hash
+	same := func(name string) uint64 {
+		h := fnv.New64()
+		_, _ = h.Write([]byte(name))
+		return h.Sum64()
+	}
+	crc := func(name string) uint64 {
+		return uint64(crc32.ChecksumIEEE([]byte(strings.ToLower(name))))
+	}
+	constant := func(string) uint64 { return 42 }
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{upper, lower, &HashOptions{FieldNameFromTag: "json"}, false},
+		{upper, lower, &HashOptions{FieldNameFromTag: "json", FieldNameHasher: crc}, true},
+		{upper, lower, &HashOptions{FieldNameFromTag: "json", FieldNameHasher: constant}, true},
+
+		// Values are still hashed
+		{upper, other, &HashOptions{FieldNameHasher: crc}, false},
+		{upper, other, &HashOptions{FieldNameHasher: constant}, false},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	for _, v := range []interface{}{upper, lower, other} {
+		expected, err := Hash(v, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		h, err := Hash(v, &HashOptions{FieldNameHasher: same})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if h != expected {
+			t.Fatalf("bad: %d != %d", h, expected)
+		}
+	}
+}
+
+func TestHash_fieldSalting(t *testing.T) {
+	type Test struct {
+		A int
+		B int
+	}
+
+	// A field name hasher hashing every name the same lets the values of
+	// the fields cancel out or move between fields unnoticed.
+	constant := func(string) uint64 { return 42 }
+	crafted := &HashOptions{FieldNameHasher: constant}
+	salted := &HashOptions{FieldNameHasher: constant, FieldSalting: true}
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{Test{A: 1, B: 1}, Test{A: 2, B: 2}, crafted, true},
+		{Test{A: 1, B: 1}, Test{A: 2, B: 2}, salted, false},
+		{Test{A: 5}, Test{B: 5}, crafted, true},
+		{Test{A: 5}, Test{B: 5}, salted, false},
+		{Test{A: 1, B: 2}, Test{A: 2, B: 1}, crafted, true},
+		{Test{A: 1, B: 2}, Test{A: 2, B: 1}, salted, false},
+
+		// Salting doesn't change which values are equal
+		{Test{A: 1, B: 2}, Test{A: 1, B: 2}, salted, true},
+		{Test{A: 5}, Test{B: 5}, &HashOptions{FieldSalting: true}, false},
+		{Test{A: 5}, Test{A: 5}, &HashOptions{FieldSalting: true}, true},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	plain, err := Hash(Test{A: 1}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	salt, err := Hash(Test{A: 1}, &HashOptions{FieldSalting: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if plain == salt {
+		t.Fatal("bad: salt not folded")
+	}
+}
+
+func TestHash_combineStats(t *testing.T) {
+	type Test struct {
+		Name string
+		Tags []string
+		Meta map[string]int
+		Set  []int `hash:"set"`
+	}
+
+	v := Test{
+		Name: "foo",
+		Tags: []string{"a", "b", "c"},
+		Meta: map[string]int{"x": 1, "y": 2},
+		Set:  []int{1, 2},
+	}
+
+	// Each field combines its name and value (4 ordered) into the struct
+	// (4 unordered). The tags combine 3 ordered, the map entries 2 ordered
+	// and 2 unordered, and the set elements 2 set combines.
+	expected := CombineStats{Ordered: 9, Unordered: 6, Set: 2}
+
+	var stats CombineStats
+	var probes int
+	_, err := Hash(v, &HashOptions{
+		CombineStats: &stats,
+		OnCollisionProbe: func(a, b, result uint64) {
+			probes++
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if stats != expected {
+		t.Fatalf("bad: %#v\n\n%#v", stats, expected)
+	}
+	if probes != int(expected.Ordered) {
+		t.Fatalf("bad: %d probes", probes)
+	}
+
+	// Stats are added up across hashes
+	if _, err := Hash(v, &HashOptions{CombineStats: &stats}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if stats.Ordered != 2*expected.Ordered {
+		t.Fatalf("bad: %#v", stats)
+	}
+}
+
+func TestHash_anonymousStructs(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			struct{ A int }{},
+			struct{ A uint }{},
+			nil,
+			true,
+		},
+		{
+			struct{ A int }{},
+			struct{ A uint }{},
+			&HashOptions{AnonymousStructs: true},
+			false,
+		},
+		{
+			struct{ A, B int8 }{},
+			struct {
+				A int8
+				B uint8
+			}{},
+			&HashOptions{AnonymousStructs: true},
+			false,
+		},
+		{
+			struct{ A int }{A: 1},
+			struct{ A int }{A: 1},
+			&HashOptions{AnonymousStructs: true},
+			true,
+		},
+		{
+			[]interface{}{struct{ A int }{}},
+			[]interface{}{struct{ A int32 }{}},
+			&HashOptions{AnonymousStructs: true},
+			false,
+		},
+
+		// Named structs keep their name
+		{
+			testPoint{},
+			testPoint{},
+			&HashOptions{AnonymousStructs: true},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	named, err := Hash(testPoint{}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	namedOpt, err := Hash(testPoint{}, &HashOptions{AnonymousStructs: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if named != namedOpt {
+		t.Fatal("named structs should hash the same")
+	}
+}
+
+func TestHash_useGetters(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// Only the getters are hashed
+		{
+			testGetters{id: 1, name: "foo", cache: 1},
+			testGetters{id: 1, name: "foo", cache: 2},
+			&HashOptions{UseGetters: true},
+			true,
+		},
+		{
+			testGetters{id: 1, name: "foo"},
+			testGetters{id: 2, name: "foo"},
+			&HashOptions{UseGetters: true},
+			false,
+		},
+		{
+			testGetters{id: 1, name: "foo"},
+			testGetters{id: 1, name: "bar"},
+			&HashOptions{UseGetters: true},
+			false,
+		},
+		{
+			&testGetters{id: 1, name: "foo", cache: 1},
+			&testGetters{id: 1, name: "foo", cache: 2},
+			&HashOptions{UseGetters: true},
+			true,
+		},
+
+		// GetterFilter picks the getters
+		{
+			testGetters{id: 1, name: "foo"},
+			testGetters{id: 1, name: "bar"},
+			&HashOptions{
+				UseGetters:   true,
+				GetterFilter: func(name string) bool { return name == "GetID" },
+			},
+			true,
+		},
+		{
+			testGetters{id: 1, name: "foo", cache: 1},
+			testGetters{id: 1, name: "foo", cache: 2},
+			&HashOptions{
+				UseGetters:   true,
+				GetterFilter: func(name string) bool { return name == "Cache" },
+			},
+			false,
+		},
+
+		// OnlyFields applies to getters
+		{
+			testGetters{id: 1, name: "foo"},
+			testGetters{id: 1, name: "bar"},
+			&HashOptions{UseGetters: true, OnlyFields: []string{"GetID"}},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	_, err := Hash(testPanickingGetter{}, &HashOptions{UseGetters: true})
+	var ep *ErrPanic
+	if !errors.As(err, &ep) || ep.Method != "GetValue" {
+		t.Fatalf("expected panic error, got: %v", err)
+	}
+}
+
+type testGetters struct {
+	id    int
+	name  string
+	cache int
+}
+
+func (g testGetters) GetID() int         { return g.id }
+func (g *testGetters) GetName() string   { return g.name }
+func (g testGetters) GetByID(id int) int { return id }
+func (g testGetters) Cache() int         { return g.cache }
+
+type testPanickingGetter struct{}
+
+func (testPanickingGetter) GetValue() int { panic("boom") }
+
+func TestHash_precedence(t *testing.T) {
+	preHash := func(v reflect.Value) (uint64, bool, error) {
+		if v.Type() != reflect.TypeOf(testGoStringer{}) {
+			return 0, false, nil
+		}
+		return 1, true, nil
+	}
+	handlers := []InterfaceHandler{
+		{
+			Iface: reflect.TypeOf((*fmt.GoStringer)(nil)).Elem(),
+			Fn:    func(reflect.Value) (uint64, error) { return 2, nil },
+		},
+	}
+
+	v := testGoStringer{Name: "foo", Cache: 1}
+	goString, err := Hash(v.GoString(), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	walked, err := Hash(v, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	when := time.Date(2020, 2, 14, 0, 0, 0, 0, time.UTC)
+	normalized, err := Hash(when, &HashOptions{NormalizeTime: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Name     string
+		Value    interface{}
+		Opts     *HashOptions
+		Expected uint64
+	}{
+		{
+			"PreHash",
+			v,
+			&HashOptions{PreHash: preHash, InterfaceHandlers: handlers, UseGoStringer: true},
+			1,
+		},
+		{
+			"InterfaceHandlers",
+			v,
+			&HashOptions{InterfaceHandlers: handlers, UseGoStringer: true},
+			2,
+		},
+		{
+			"InterfaceHandlers before NormalizeTime",
+			when,
+			&HashOptions{InterfaceHandlers: handlers, NormalizeTime: true},
+			2,
+		},
+		{
+			"NormalizeTime before UseGoStringer",
+			when,
+			&HashOptions{NormalizeTime: true, UseGoStringer: true},
+			normalized,
+		},
+		{
+			"UseGoStringer",
+			v,
+			&HashOptions{UseGoStringer: true},
+			goString,
+		},
+		{
+			"UseGoStringer through a pointer",
+			&v,
+			&HashOptions{UseGoStringer: true},
+			goString,
+		},
+		{
+			"reflection",
+			v,
+			nil,
+			walked,
+		},
+	}
+
+	for _, tc := range cases {
+		h, err := Hash(tc.Value, tc.Opts)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Name, err)
+		}
+		if h != tc.Expected {
+			t.Fatalf("%s: bad, expected %d, got %d", tc.Name, tc.Expected, h)
+		}
+	}
+
+	if walked == goString {
+		t.Fatal("reflection should not use GoString")
+	}
+}
+
+func TestHash_includeUnexported(t *testing.T) {
+	type Inner struct {
+		port int
+	}
+
+	type Test struct {
+		Name  string
+		id    int
+		inner Inner
+		value testBothStringers `hash:"string"`
+		tags  []string          `hash:"set"`
+		_     int
+	}
+
+	opts := &HashOptions{IncludeUnexported: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			Test{Name: "foo", id: 1},
+			Test{Name: "foo", id: 2},
+			nil,
+			true,
+		},
+		{
+			Test{Name: "foo", id: 1},
+			Test{Name: "foo", id: 2},
+			opts,
+			false,
+		},
+		{
+			Test{inner: Inner{port: 80}},
+			Test{inner: Inner{port: 443}},
+			opts,
+			false,
+		},
+		{
+			&Test{id: 1},
+			&Test{id: 1},
+			opts,
+			true,
+		},
+		{
+			map[string]Test{"a": {id: 1}},
+			map[string]Test{"a": {id: 2}},
+			opts,
+			false,
+		},
+
+		// Tags work on unexported fields
+		{
+			Test{value: testBothStringers{Name: "foo", Go: "a"}},
+			Test{value: testBothStringers{Name: "foo", Go: "b"}},
+			opts,
+			true,
+		},
+		{
+			Test{value: testBothStringers{Name: "foo"}},
+			Test{value: testBothStringers{Name: "bar"}},
+			opts,
+			false,
+		},
+		{
+			Test{tags: []string{"a", "b"}},
+			Test{tags: []string{"b", "a"}},
+			opts,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_maxMapEntries(t *testing.T) {
+	bigMap := func(n int, extra map[int]string) map[int]string {
+		m := make(map[int]string, n)
+		for i := 0; i < n; i++ {
+			m[i] = strconv.Itoa(i)
+		}
+		for k, v := range extra {
+			m[k] = v
+		}
+		return m
+	}
+
+	opts := &HashOptions{MaxMapEntries: 10}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			bigMap(1000, nil),
+			bigMap(1000, nil),
+			opts,
+			true,
+		},
+
+		// The number of entries is hashed
+		{
+			bigMap(1000, nil),
+			bigMap(1001, nil),
+			opts,
+			false,
+		},
+		{
+			bigMap(5000, nil),
+			bigMap(5000, map[int]string{-1: "x"}),
+			opts,
+			false,
+		},
+
+		// Small maps are hashed as usual
+		{
+			bigMap(5, nil),
+			bigMap(5, map[int]string{4: "x"}),
+			opts,
+			false,
+		},
+		{
+			map[string]int{"a": 1},
+			map[string]int{"a": 1},
+			&HashOptions{MaxMapEntries: 1},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Small maps hash like without the option
+	small := map[string]int{"a": 1, "b": 2}
+	limited, err := Hash(small, &HashOptions{MaxMapEntries: 2})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	full, err := Hash(small, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if limited != full {
+		t.Fatal("maps within the limit should hash as usual")
+	}
+
+	// Exactly one of the entries of a two-entry map is skipped
+	one, err := Hash(map[string]int{"a": 1, "b": 2}, &HashOptions{MaxMapEntries: 1})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash(map[string]int{"a": 3, "b": 4}, &HashOptions{MaxMapEntries: 1})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	changedA, err := Hash(map[string]int{"a": 3, "b": 2}, &HashOptions{MaxMapEntries: 1})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one == two || (changedA != one) == (changedA != two) {
+		t.Fatal("expected exactly one entry to be hashed")
+	}
+}
+
+func TestHash_stringTransforms(t *testing.T) {
+	type Test struct {
+		Tags []string `hash:"set,lower"`
+	}
+
+	type Name string
+	type Other struct {
+		Name  Name      `hash:"trim,lower"`
+		Codes [2]string `hash:"upper"`
+		Keys  []string  `hash:"lower"`
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			Test{Tags: []string{"A", "b"}},
+			Test{Tags: []string{"B", "a"}},
+			true,
+		},
+		{
+			Test{Tags: []string{"A", "b"}},
+			Test{Tags: []string{"a", "c"}},
+			false,
+		},
+		{
+			Test{Tags: []string{"a", "a"}},
+			Test{Tags: []string{"A"}},
+			false,
+		},
+		{
+			Other{Name: " Foo "},
+			Other{Name: "foo"},
+			true,
+		},
+		{
+			Other{Codes: [2]string{"us", "De"}},
+			Other{Codes: [2]string{"US", "DE"}},
+			true,
+		},
+
+		// Element transforms keep the order of slices
+		{
+			Other{Keys: []string{"A", "b"}},
+			Other{Keys: []string{"a", "B"}},
+			true,
+		},
+		{
+			Other{Keys: []string{"A", "b"}},
+			Other{Keys: []string{"b", "a"}},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The original values are not modified
+	v := Test{Tags: []string{"A"}}
+	if _, err := Hash(v, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v.Tags[0] != "A" {
+		t.Fatalf("value was modified: %#v", v)
+	}
+}
+
+func TestHash_stringTransformsInvalid(t *testing.T) {
+	cases := []interface{}{
+		struct {
+			Tags []string `hash:"set,shout"`
+		}{},
+		struct {
+			Count int `hash:"lower"`
+		}{},
+		struct {
+			Items []int `hash:"set,lower"`
+		}{},
+	}
+
+	for _, tc := range cases {
+		if _, err := Hash(tc, nil); err == nil {
+			t.Fatalf("expected error hashing %#v", tc)
+		}
+	}
+}
+
+func TestHash_reflectType(t *testing.T) {
+	type Test struct {
+		Type reflect.Type
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			reflect.TypeOf(0),
+			reflect.TypeOf(1),
+			true,
+		},
+		{
+			reflect.TypeOf(0),
+			reflect.TypeOf(""),
+			false,
+		},
+		{
+			reflect.TypeOf([]int{}),
+			reflect.TypeOf([]int64{}),
+			false,
+		},
+		{
+			Test{Type: reflect.TypeOf(time.Time{})},
+			Test{Type: reflect.TypeOf(time.Time{})},
+			true,
+		},
+		{
+			Test{Type: reflect.TypeOf(time.Time{})},
+			Test{Type: reflect.TypeOf(time.Duration(0))},
+			false,
+		},
+		{
+			Test{Type: reflect.TypeOf(time.Time{})},
+			Test{},
+			false,
+		},
+		{
+			map[reflect.Type]string{reflect.TypeOf(0): "int"},
+			map[reflect.Type]string{reflect.TypeOf(uint(0)): "int"},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_mapSets(t *testing.T) {
+	type Empty struct{}
+
+	opts := &HashOptions{MapSets: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			map[string]struct{}{"a": {}, "b": {}},
+			map[string]struct{}{"a": {}},
+			opts,
+			false,
+		},
+		{
+			map[string]struct{}{"a": {}, "b": {}},
+			map[string]struct{}{"b": {}, "a": {}},
+			opts,
+			true,
+		},
+		{
+			map[int]Empty{1: {}, 2: {}},
+			map[int]Empty{1: {}, 3: {}},
+			opts,
+			false,
+		},
+
+		// Other maps hash as usual
+		{
+			map[string]int{"a": 1},
+			map[string]int{"a": 1},
+			opts,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Set maps hash like a slice of their keys tagged hash:"set"
+	var one, two uint64
+	var err error
+	{
+		type Test struct {
+			Keys map[string]struct{}
+		}
+		one, err = Hash(Test{Keys: map[string]struct{}{"a": {}, "b": {}}}, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	{
+		type Test struct {
+			Keys []string `hash:"set"`
+		}
+		two, err = Hash(Test{Keys: []string{"b", "a"}}, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if one != two {
+		t.Fatal("set maps should hash like sets of their keys")
+	}
+}
+
+func TestHash_pointerValueEquivalent(t *testing.T) {
+	handlers := []InterfaceHandler{
+		{
+			Iface: reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+			Fn: func(v reflect.Value) (uint64, error) {
+				return Hash(v.Interface().(fmt.Stringer).String(), nil)
+			},
+		},
+	}
+
+	v := testPointerStringer{Name: "foo", Cache: 1}
+	w := testPointerStringer{Name: "foo", Cache: 2}
+	opts := &HashOptions{PointerValueEquivalent: true, InterfaceHandlers: handlers}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// The handler only applies to pointers without the option
+		{
+			&v,
+			v,
+			&HashOptions{InterfaceHandlers: handlers},
+			false,
+		},
+		{
+			&v,
+			v,
+			opts,
+			true,
+		},
+		{
+			v,
+			w,
+			opts,
+			true,
+		},
+		{
+			[]*testPointerStringer{&v},
+			[]testPointerStringer{w},
+			opts,
+			true,
+		},
+		{
+			map[string]interface{}{"a": &v},
+			map[string]interface{}{"a": w},
+			opts,
+			true,
+		},
+
+		// Interface types ignore pointers
+		{
+			[]interface{}{&v},
+			[]interface{}{v},
+			&HashOptions{IncludeInterfaceType: true},
+			false,
+		},
+		{
+			[]interface{}{&v},
+			[]interface{}{v},
+			&HashOptions{IncludeInterfaceType: true, PointerValueEquivalent: true},
+			true,
+		},
+
+		// Nil pointers still differ from zero values
+		{
+			[]*testPointerStringer{nil},
+			[]testPointerStringer{{}},
+			opts,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Structs that only differ in pointer and value fields
+	var one, two uint64
+	var err error
+	{
+		type Test struct {
+			Value *testPointerStringer
+			Items []*testPointerStringer
+		}
+		one, err = Hash(Test{Value: &v, Items: []*testPointerStringer{&v}}, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	{
+		type Test struct {
+			Value testPointerStringer
+			Items []testPointerStringer
+		}
+		two, err = Hash(Test{Value: w, Items: []testPointerStringer{w}}, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if one != two {
+		t.Fatal("pointer and value fields should hash the same")
+	}
+}
+
+type testPointerStringer struct {
+	Name  string
+	Cache int
+}
+
+func (t *testPointerStringer) String() string { return t.Name }
+
+func TestHash_nonZeroEmptyStructs(t *testing.T) {
+	type Ignored struct {
+		Name string `hash:"ignore"`
+	}
+
+	opts := &HashOptions{NonZeroEmptyStructs: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			struct{}{},
+			struct {
+				Name string `hash:"ignore"`
+			}{},
+			nil,
+			true,
+		},
+		{
+			struct{}{},
+			struct {
+				Name string `hash:"ignore"`
+			}{},
+			opts,
+			true,
+		},
+		{
+			struct{}{},
+			"",
+			nil,
+			true,
+		},
+		{
+			struct{}{},
+			"",
+			opts,
+			false,
+		},
+		{
+			image.Point{},
+			image.Point{},
+			opts,
+			true,
+		},
+		{
+			Ignored{Name: "foo"},
+			Ignored{Name: "bar"},
+			opts,
+			true,
+		},
+		{
+			[]interface{}{struct{}{}, struct{ A int }{}},
+			[]interface{}{struct{}{}, struct{ A int }{}},
+			opts,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	plain, err := Hash(struct{}{}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	marked, err := Hash(struct{}{}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if plain == marked {
+		t.Fatal("empty structs should fold their type")
+	}
+
+	// Structs with hashed fields are unchanged
+	withField, err := Hash(image.Point{X: 1}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	withoutOpt, err := Hash(image.Point{X: 1}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if withField != withoutOpt {
+		t.Fatal("structs with hashed fields should hash as usual")
+	}
+}
+
+func TestHash_tagNames(t *testing.T) {
+	type Test struct {
+		ID    string            `sighash:"ignore"`
+		Tags  []string          `cachehash:"set"`
+		Value testBothStringers `cachehash:"string" sighash:"ignore"`
+		Sig   string            `cachehash:"-"`
+	}
+
+	cache := &HashOptions{TagName: "cachehash"}
+	sig := &HashOptions{TagName: "sighash"}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// Ignoring
+		{
+			Test{ID: "a"},
+			Test{ID: "b"},
+			cache,
+			false,
+		},
+		{
+			Test{ID: "a"},
+			Test{ID: "b"},
+			sig,
+			true,
+		},
+		{
+			Test{Sig: "a"},
+			Test{Sig: "b"},
+			cache,
+			true,
+		},
+		{
+			Test{Sig: "a"},
+			Test{Sig: "b"},
+			sig,
+			false,
+		},
+
+		// Sets
+		{
+			Test{Tags: []string{"a", "b"}},
+			Test{Tags: []string{"b", "a"}},
+			cache,
+			true,
+		},
+		{
+			Test{Tags: []string{"a", "b"}},
+			Test{Tags: []string{"b", "a"}},
+			sig,
+			false,
+		},
+
+		// Strings
+		{
+			Test{Value: testBothStringers{Name: "a", Go: "1"}},
+			Test{Value: testBothStringers{Name: "a", Go: "2"}},
+			cache,
+			true,
+		},
+		{
+			Test{Value: testBothStringers{Name: "a", Go: "1"}},
+			Test{Value: testBothStringers{Name: "b", Go: "2"}},
+			sig,
+			true,
+		},
+
+		// The default tag doesn't apply, but Includable always does
+		{
+			testIncludableTags{Name: "a", Cache: "1"},
+			testIncludableTags{Name: "a", Cache: "2"},
+			cache,
+			false,
+		},
+		{
+			testIncludableTags{Name: "a", Cache: "1"},
+			testIncludableTags{Name: "a", Cache: "2"},
+			nil,
+			true,
+		},
+		{
+			testIncludableTags{Name: "a", Extra: "1"},
+			testIncludableTags{Name: "a", Extra: "2"},
+			cache,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Errors name the tag in use
+	type Invalid struct {
+		Count int    `cachehash:"string"`
+		Items int    `cachehash:"lenonly"`
+		Name  string `cachehash:"lower,bogus"`
+		Total int    `cachehash:"method:Missing"`
+	}
+	_, err := Hash(Invalid{}, &HashOptions{TagName: "cachehash", CollectErrors: true})
+	if err == nil {
+		t.Fatal("expected errors naming the tag")
+	}
+	for _, s := range []string{`cachehash:"string"`, `cachehash:"lenonly"`, `unknown cachehash tag option "bogus"`, `cachehash:"method:Missing"`} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("expected error containing %s, got: %s", s, err)
+		}
+	}
+}
+
+// testIncludableTags excludes Extra with HashInclude, whatever the tag
+// name, and Cache with the hash tag.
+type testIncludableTags struct {
+	Name  string
+	Cache string `hash:"ignore"`
+	Extra string
+}
+
+func (t testIncludableTags) HashInclude(field string, v interface{}) (bool, error) {
+	return field != "Extra", nil
+}
+
+func TestHash_nilSliceAsEmpty(t *testing.T) {
+	type Test struct {
+		Items []string
+		Set   []string `hash:"set"`
+		Nums  []int
+		Meta  map[string]string
+	}
+
+	empty := Test{Items: []string{}, Set: []string{}, Nums: []int{}, Meta: map[string]string{}}
+	for _, opts := range []*HashOptions{nil, {Iterative: true}, {BulkNumbers: true}, {ZeroNil: true}} {
+		cases := []struct {
+			One, Two interface{}
+		}{
+			{Test{}, empty},
+			{[]string(nil), []string{}},
+			{[][]int{nil}, [][]int{{}}},
+			{map[string][]int{"a": nil}, map[string][]int{"a": {}}},
+			{map[string]string(nil), map[string]string{}},
+		}
+
+		for _, tc := range cases {
+			one, err := Hash(tc.One, opts)
+			if err != nil {
+				t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+			}
+			two, err := Hash(tc.Two, opts)
+			if err != nil {
+				t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+			}
+			if one != two {
+				t.Fatalf("nil and empty should hash the same with %#v:\n\n%#v\n\n%#v", opts, tc.One, tc.Two)
+			}
+		}
+	}
+}
+
+func TestHash_headerMaps(t *testing.T) {
+	type Request struct {
+		Method string
+		Header http.Header
+	}
+
+	opts := &HashOptions{HeaderMaps: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{
+			http.Header{"Accept": {"text/html", "application/json"}},
+			http.Header{"Accept": {"application/json", "text/html"}},
+			nil,
+			false,
+		},
+		{
+			http.Header{"Accept": {"text/html", "application/json"}},
+			http.Header{"Accept": {"application/json", "text/html"}},
+			opts,
+			true,
+		},
+		{
+			http.Header{"X-Request-Id": {"1"}},
+			http.Header{"x-request-id": {"1"}},
+			opts,
+			true,
+		},
+		{
+			http.Header{"Accept": {"a"}, "accept": {"b"}},
+			http.Header{"Accept": {"b", "a"}},
+			opts,
+			true,
+		},
+		{
+			http.Header{"Accept": {"a"}},
+			http.Header{"Accept": {"a", "a"}},
+			opts,
+			false,
+		},
+		{
+			http.Header{"Accept": {"a"}},
+			http.Header{"Accept": {"b"}},
+			opts,
+			false,
+		},
+		{
+			http.Header{"Accept": {"a"}},
+			http.Header{"Accept": {"a"}, "Vary": nil},
+			opts,
+			false,
+		},
+		{
+			Request{Method: "GET", Header: http.Header{"Accept": {"a", "b"}}},
+			Request{Method: "GET", Header: http.Header{"accept": {"b", "a"}}},
+			opts,
+			true,
+		},
+
+		// Other maps hash as usual
+		{
+			map[string][]int{"a": {1, 2}},
+			map[string][]int{"a": {2, 1}},
+			opts,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_callTag(t *testing.T) {
+	type Test struct {
+		Name  string
+		Count func() int `hash:"call"`
+	}
+
+	count := func(n int) func() int { return func() int { return n } }
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			Test{Name: "foo", Count: count(1)},
+			Test{Name: "foo", Count: count(1)},
+			true,
+		},
+		{
+			Test{Name: "foo", Count: count(1)},
+			Test{Name: "foo", Count: count(2)},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The result is hashed like a field holding it
+	var one, two uint64
+	var err error
+	{
+		type Test struct {
+			Count func() int `hash:"call"`
+		}
+		one, err = Hash(Test{Count: count(3)}, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	{
+		type Test struct {
+			Count int
+		}
+		two, err = Hash(Test{Count: 3}, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if one != two {
+		t.Fatal("expected the result to hash like a field")
+	}
+
+	// Errors of the function are returned
+	type WithError struct {
+		Value func() (string, error) `hash:"call"`
+	}
+	_, err = Hash(WithError{Value: func() (string, error) { return "", errors.New("boom") }}, nil)
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected error, got: %v", err)
+	}
+}
+
+func TestHash_callTagInvalid(t *testing.T) {
+	cases := []interface{}{
+		struct {
+			Count int `hash:"call"`
+		}{},
+		struct {
+			Count func() int `hash:"call"`
+		}{},
+		struct {
+			Count func(int) int `hash:"call"`
+		}{Count: func(int) int { return 0 }},
+		struct {
+			Count func() `hash:"call"`
+		}{Count: func() {}},
+		struct {
+			Count func() (int, int) `hash:"call"`
+		}{Count: func() (int, int) { return 0, 0 }},
+	}
+
+	for _, tc := range cases {
+		if _, err := Hash(tc, nil); err == nil {
+			t.Fatalf("expected error hashing %#v", tc)
+		}
+	}
+
+	_, err := Hash(struct {
+		Count func() int `hash:"call"`
+	}{Count: func() int { panic("boom") }}, nil)
+	var ep *ErrPanic
+	if !errors.As(err, &ep) || ep.Field != "Count" {
+		t.Fatalf("expected panic error, got: %v", err)
+	}
+}
+
+func TestHash_mapPointerKeys(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{
+			map[*string]int{str("a"): 1, str("b"): 2},
+			map[*string]int{str("b"): 2, str("a"): 1},
+			true,
+		},
+		{
+			map[*string]int{str("a"): 1},
+			map[*string]int{str("a"): 2},
+			false,
+		},
+
+		// Keys with the same content
+		{
+			map[*string]int{str("a"): 1, str("a"): 2},
+			map[*string]int{str("a"): 2, str("a"): 1},
+			true,
+		},
+		{
+			map[*string]int{str("a"): 1, str("a"): 1},
+			map[*string]int{},
+			false,
+		},
+		{
+			map[*string]int{str("a"): 1, str("a"): 1},
+			map[*string]int{str("a"): 1},
+			false,
+		},
+		{
+			map[*string]int{str("a"): 1, str("a"): 1, str("b"): 2},
+			map[*string]int{str("b"): 2},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+
+		// Map iteration order doesn't change the hash
+		for i := 0; i < 10; i++ {
+			again, err := Hash(tc.One, nil)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if again != one {
+				t.Fatalf("unstable hash of %#v: %d != %d", tc.One, again, one)
+			}
+		}
+	}
+}
+
+func TestHash_skipNilInterfaces(t *testing.T) {
+	var one, two uint64
+	var err error
+	opts := &HashOptions{SkipNilInterfaces: true}
+	{
+		type Test struct {
+			Name  string
+			Value interface{}
+		}
+		one, err = Hash(Test{Name: "foo"}, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	{
+		type Test struct {
+			Name string
+		}
+		two, err = Hash(Test{Name: "foo"}, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if one != two {
+		t.Fatal("expected a nil interface field to be skipped")
+	}
+
+	type Test struct {
+		Name  string
+		Value interface{}
+		Err   error
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// Without the option a nil interface hashes like a zero int
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Value: 0},
+			nil,
+			true,
+		},
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Value: 0},
+			opts,
+			false,
+		},
+		{
+			Test{Name: "foo", Value: "bar"},
+			Test{Name: "foo", Value: "bar"},
+			opts,
+			true,
+		},
+
+		// A nil pointer in an interface isn't a nil interface
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Value: (*int)(nil)},
+			opts,
+			false,
+		},
+
+		// Nil interfaces have no type to include
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo", Value: 0},
+			&HashOptions{SkipNilInterfaces: true, IncludeInterfaceType: true},
+			false,
+		},
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo"},
+			&HashOptions{SkipNilInterfaces: true, IncludeInterfaceType: true},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
+
+func TestHash_includeMethodSet(t *testing.T) {
+	sameName := func(reflect.Type) string { return "Plugin" }
+	opts := &HashOptions{IncludeMethodSet: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// Same name and fields, but different methods
+		{testPluginA{Name: "foo"}, testPluginB{Name: "foo"}, &HashOptions{StructNameFunc: sameName}, true},
+		{testPluginA{Name: "foo"}, testPluginB{Name: "foo"}, &HashOptions{StructNameFunc: sameName, IncludeMethodSet: true}, false},
+		{testPluginB{Name: "foo"}, testPluginC{Name: "foo"}, &HashOptions{StructNameFunc: sameName, IncludeMethodSet: true}, false},
+		{testPluginA{Name: "foo"}, testPluginA{Name: "foo"}, opts, true},
+		{testPluginA{Name: "foo"}, testPluginA{Name: "bar"}, opts, false},
+		{&testPluginA{Name: "foo"}, testPluginA{Name: "foo"}, opts, true},
+		{[]testPluginA{{Name: "foo"}}, []testPluginA{{Name: "foo"}}, opts, true},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// A type without methods hashes like without the option
+	{
+		type testPluginA struct {
+			Name string
+		}
+		one, err := Hash(testPluginA{Name: "foo"}, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		two, err := Hash(testPluginA{Name: "foo"}, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if one != two {
+			t.Fatal("bad: an empty method set changed the hash")
+		}
+	}
+}
+
+type testPluginA struct {
+	Name string
+}
+
+func (testPluginA) Start() error { return nil }
+
+type testPluginB struct {
+	Name string
+}
+
+func (testPluginB) Start() error { return nil }
+func (*testPluginB) Stop()       {}
+
+type testPluginC struct {
+	Name string
+}
+
+func (testPluginC) Start(force bool) error { return nil }
+func (testPluginC) Stop()                  {}
+
+func TestHash_mapValueTransform(t *testing.T) {
+	trim := func(v reflect.Value) (reflect.Value, error) {
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.String {
+			return reflect.ValueOf(strings.TrimSpace(v.String())), nil
+		}
+		return v, nil
+	}
+
+	type Test struct {
+		Name   string
+		Config map[string]string
+	}
+
+	opts := &HashOptions{MapValueTransform: trim}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{map[string]string{"a": " foo "}, map[string]string{"a": "foo"}, opts, true},
+		{map[string]string{"a": " foo "}, map[string]string{"a": "foo"}, nil, false},
+		{map[string]string{"a": "foo"}, map[string]string{"a": "bar"}, opts, false},
+		{map[string]interface{}{"a": "foo\n", "b": 1}, map[string]interface{}{"a": "foo", "b": 1}, opts, true},
+		{map[string]interface{}{"a": "foo", "b": 1}, map[string]interface{}{"a": "foo", "b": 2}, opts, false},
+		{
+			Test{Name: "foo", Config: map[string]string{"a": "x ", "b": "\ty"}},
+			Test{Name: "foo", Config: map[string]string{"a": "x", "b": "y"}},
+			opts,
+			true,
+		},
+
+		// Keys are hashed as they are, and so is anything that isn't a map value
+		{map[string]string{" a": "foo"}, map[string]string{"a": "foo"}, opts, false},
+		{Test{Name: " foo"}, Test{Name: "foo"}, opts, false},
+
+		// Nested maps are transformed too
+		{
+			map[string]map[string]string{"a": {"b": " foo"}},
+			map[string]map[string]string{"a": {"b": "foo"}},
+			opts,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Errors of the transform are returned
+	failing := func(reflect.Value) (reflect.Value, error) {
+		return reflect.Value{}, errors.New("transform failed")
+	}
+	_, err := Hash(map[string]int{"a": 1}, &HashOptions{MapValueTransform: failing})
+	if err == nil || err.Error() != "transform failed" {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestHash_orderedCombiner(t *testing.T) {
+	type Test struct {
+		Name  string
+		Tags  []string
+		Meta  map[string]int
+		Count int
+	}
+	v := Test{Name: "foo", Tags: []string{"a", "b"}, Meta: map[string]int{"x": 1}, Count: 2}
+
+	// A combiner doing what the default one does hashes the same
+	var calls int
+	fnvCombiner := func(h hash.Hash64, a, b uint64) uint64 {
+		calls++
+		var buf [16]byte
+		binary.LittleEndian.PutUint64(buf[:8], a)
+		binary.LittleEndian.PutUint64(buf[8:], b)
+		h.Reset()
+		_, _ = h.Write(buf[:])
+		return h.Sum64()
+	}
+	expected, err := Hash(v, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	actual, err := Hash(v, &HashOptions{OrderedCombiner: fnvCombiner})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual != expected || calls == 0 {
+		t.Fatalf("bad: %d != %d after %d calls", actual, expected, calls)
+	}
+
+	// A different combiner changes the hashes, but they still tell values
+	// apart
+	opts := &HashOptions{OrderedCombiner: testMixCombiner}
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		{v, v, true},
+		{[]string{"a", "b"}, []string{"b", "a"}, false},
+		{[]int{1, 0}, []int{1, 0, 0}, false},
+		{Test{Name: "foo"}, Test{Name: "bar"}, false},
+		{v, Test{Name: "foo", Tags: []string{"b", "a"}, Meta: map[string]int{"x": 1}, Count: 2}, false},
+	}
+	for _, tc := range cases {
+		one, err := Hash(tc.One, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	mixed, err := Hash(v, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mixed == expected {
+		t.Fatal("bad: combiner not used")
+	}
+}
+
+func BenchmarkHash_orderedCombiner(b *testing.B) {
+	v := testNumbersValue()
+	for _, tc := range []struct {
+		Name string
+		Opts *HashOptions
+	}{
+		{"default", &HashOptions{}},
+		{"mix", &HashOptions{OrderedCombiner: testMixCombiner}},
+	} {
+		b.Run(tc.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Hash(v, tc.Opts); err != nil {
+					b.Fatalf("err: %s", err)
+				}
+			}
+		})
+	}
+}
+
+// testMixCombiner is an ordered combiner that mixes its inputs with
+// multiplications instead of writing them to the Hasher.
+func testMixCombiner(_ hash.Hash64, a, b uint64) uint64 {
+	h := a*0x9e3779b97f4a7c15 ^ b
+	h ^= h >> 31
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 29
+	return h
+}
+
+func TestHash_oneOf(t *testing.T) {
+	type Circle struct {
+		Radius int
+	}
+	type Square struct {
+		Side int
+	}
+	type Shape struct {
+		Type   string
+		Circle *Circle `hash:"oneof=Type"`
+		Square *Square `hash:"oneof=Type"`
+	}
+	type Contact struct {
+		Kind  int
+		Email string `hash:"oneof=Kind:1"`
+		Phone string `hash:"oneof=Kind:2|3"`
+	}
+
+	var removed interface{}
+	{
+		type Shape struct {
+			Type string
+		}
+		removed = Shape{Type: "other"}
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Match    bool
+	}{
+		// Inactive fields don't affect the hash
+		{
+			Shape{Type: "circle", Circle: &Circle{1}, Square: &Square{2}},
+			Shape{Type: "circle", Circle: &Circle{1}},
+			true,
+		},
+		{
+			Shape{Type: "square", Circle: &Circle{1}, Square: &Square{2}},
+			Shape{Type: "square", Circle: &Circle{3}, Square: &Square{2}},
+			true,
+		},
+		{
+			Shape{Type: "CIRCLE", Circle: &Circle{1}, Square: &Square{2}},
+			Shape{Type: "CIRCLE", Circle: &Circle{1}, Square: &Square{3}},
+			true,
+		},
+		{
+			Contact{Kind: 1, Email: "a@example.com", Phone: "123"},
+			Contact{Kind: 1, Email: "a@example.com"},
+			true,
+		},
+		{
+			Contact{Kind: 3, Email: "a@example.com", Phone: "123"},
+			Contact{Kind: 3, Email: "b@example.com", Phone: "123"},
+			true,
+		},
+
+		// Active fields do
+		{
+			Shape{Type: "circle", Circle: &Circle{1}},
+			Shape{Type: "circle", Circle: &Circle{2}},
+			false,
+		},
+		{
+			Contact{Kind: 2, Phone: "123"},
+			Contact{Kind: 2, Phone: "456"},
+			false,
+		},
+
+		// And so does the discriminator
+		{
+			Shape{Type: "circle", Circle: &Circle{1}},
+			Shape{Type: "square", Circle: &Circle{1}},
+			false,
+		},
+
+		// Inactive fields hash as if they were removed
+		{
+			Shape{Type: "other", Circle: &Circle{1}, Square: &Square{2}},
+			removed,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The discriminator must exist
+	type Broken struct {
+		Value string `hash:"oneof=Type"`
+	}
+	_, err := Hash(Broken{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "there is no field Type") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestHash_treatEmptyAndAbsentEqual(t *testing.T) {
+	type Config struct {
+		Name    string
+		Options map[string]interface{}
+	}
+
+	opts := &HashOptions{TreatEmptyAndAbsentEqual: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{map[string]int{"a": 0}, map[string]int{}, opts, true},
+		{map[string]int{"a": 0}, map[string]int(nil), opts, true},
+		{map[string]int{"a": 0}, map[string]int{}, nil, false},
+		{map[string]int{"a": 0}, map[string]int{"a": 1}, opts, false},
+		{map[string]int{"a": 1, "b": 0}, map[string]int{"a": 1, "c": 0}, opts, true},
+		{map[string]string{"a": ""}, map[string]string{}, opts, true},
+		{map[string]*int{"a": nil}, map[string]*int{}, opts, true},
+		{map[string]*int{"a": new(int)}, map[string]*int{}, opts, false},
+		{map[string][]int{"a": nil}, map[string][]int{}, opts, true},
+		{map[string][]int{"a": {}}, map[string][]int{"a": nil}, opts, true},
+		{map[string][]int{"a": {}}, map[string][]int{}, opts, true},
+		{map[string]interface{}{"a": []string{}}, map[string]interface{}{"a": nil}, opts, true},
+		{
+			Config{Name: "foo", Options: map[string]interface{}{"debug": false, "level": 0, "tag": nil}},
+			Config{Name: "foo"},
+			opts,
+			true,
+		},
+		{
+			Config{Name: "foo", Options: map[string]interface{}{"debug": true}},
+			Config{Name: "foo"},
+			opts,
+			false,
+		},
+
+		// The skipped entries aren't counted by MaxMapEntries
+		{
+			map[string]int{"a": 1, "b": 2, "c": 0},
+			map[string]int{"a": 1, "b": 2},
+			&HashOptions{TreatEmptyAndAbsentEqual: true, MaxMapEntries: 1},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
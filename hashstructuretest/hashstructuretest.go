@@ -0,0 +1,37 @@
+// Package hashstructuretest provides utilities for testing how well a
+// hashstructure configuration suits a set of values.
+package hashstructuretest
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/mitchellh/hashstructure"
+)
+
+// FindCollisions hashes every value of values with opts and returns the
+// index pairs of values that hash the same even though they are not
+// reflect.DeepEqual. Within each pair the first index is the smaller one,
+// and the pairs are ordered by their second index.
+//
+// FindCollisions panics if a value cannot be hashed, since the corpus is
+// expected to be hashable with opts.
+func FindCollisions(values []interface{}, opts *hashstructure.HashOptions) [][2]int {
+	var result [][2]int
+	seen := make(map[uint64][]int)
+	for i, v := range values {
+		h, err := hashstructure.Hash(v, opts)
+		if err != nil {
+			panic(fmt.Sprintf("hashstructuretest: error hashing value %d: %s", i, err))
+		}
+
+		for _, j := range seen[h] {
+			if !reflect.DeepEqual(values[j], v) {
+				result = append(result, [2]int{j, i})
+			}
+		}
+		seen[h] = append(seen[h], i)
+	}
+
+	return result
+}
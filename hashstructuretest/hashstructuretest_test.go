@@ -0,0 +1,65 @@
+package hashstructuretest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mitchellh/hashstructure"
+)
+
+func TestFindCollisions(t *testing.T) {
+	values := []interface{}{
+		"1",
+		[]string{"a", "b"},
+		uint8('1'), // hashes the same single byte as "1"
+		"1",
+		[]string{"b", "a"},
+		uint8('1'),
+	}
+
+	expected := [][2]int{{0, 2}, {2, 3}, {0, 5}, {3, 5}}
+	actual := FindCollisions(values, nil)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v\n\n%#v", actual, expected)
+	}
+}
+
+func TestFindCollisions_opts(t *testing.T) {
+	values := []interface{}{
+		[]interface{}{"1"},
+		[]interface{}{uint8('1')},
+		[]interface{}{"1"},
+	}
+
+	cases := []struct {
+		Opts     *hashstructure.HashOptions
+		Expected [][2]int
+	}{
+		{
+			nil,
+			[][2]int{{0, 1}, {1, 2}},
+		},
+
+		{
+			&hashstructure.HashOptions{IncludeInterfaceType: true},
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		actual := FindCollisions(values, tc.Opts)
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("bad: %#v\n\n%#v", actual, tc.Expected)
+		}
+	}
+}
+
+func TestFindCollisions_error(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("should panic")
+		}
+	}()
+
+	FindCollisions([]interface{}{1}, &hashstructure.HashOptions{OnlyFields: []string{"Name"}})
+}
@@ -9,7 +9,25 @@ type Includable interface {
 
 // IncludableMap is an interface that can optionally be implemented by
 // a struct. It will be called when a map-type field is found to ask the
-// struct if the map item should be included in the hash.
+// struct if the map item should be included in the hash. It is also
+// called for the maps held by a field tagged hash:"set", with the name of
+// that field.
 type IncludableMap interface {
 	HashIncludeMap(field string, k, v interface{}) (bool, error)
 }
+
+// SelfIncludable is an interface that can optionally be implemented by
+// any value. It will be called when the value is found as a struct field,
+// map value, or slice or array element to ask the value itself whether it
+// should be included in the hash.
+type SelfIncludable interface {
+	HashSelfInclude() (bool, error)
+}
+
+// OrderedMapper is an interface that can optionally be implemented by a
+// map type. The map entries are then hashed in the order of the returned
+// keys, which must list every key of the map exactly once, instead of being
+// hashed as an unordered collection.
+type OrderedMapper interface {
+	HashKeyOrder() []interface{}
+}
@@ -0,0 +1,148 @@
+package hashstructure
+
+import (
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// jsonFastPath reports whether values decoded from JSON, such as by
+// json.Unmarshal into an interface{}, can be hashed by hashJSON. None of
+// the options that change how their types are hashed, or that need to
+// see every value, may be set.
+func (w *walker) jsonFastPath() bool {
+	return !w.reflectJSON &&
+		w.preHash == nil &&
+		w.onVisit == nil &&
+		len(w.interfaceHandlers) == 0 &&
+		w.defaultPrototypes == nil &&
+		!w.canonical &&
+		!w.trackPath &&
+		!w.iterative &&
+		!w.includeInterfaceType &&
+		!w.numericCoercion &&
+		!w.normalize &&
+		!w.mapCanonicalJSON &&
+		!w.pointerValueEquivalent &&
+		!w.rejectFloats &&
+		!w.roundFloatsToInt &&
+		w.mapValueTransform == nil &&
+		!w.treatEmptyAndAbsentEqual &&
+		w.maxMapEntries == 0
+}
+
+// hashJSON hashes v if it is a map[string]interface{} or []interface{},
+// using type assertions instead of reflection for the values it is made
+// of. The hash is the same as visitValue returns. Values of other types in
+// it are still hashed by visit.
+func (w *walker) hashJSON(v reflect.Value) (uint64, bool, error) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return 0, false, nil
+	}
+
+	// Compare the types first, so other values aren't copied by Interface
+	switch v.Type() {
+	case jsonObjectType:
+		h, err := w.hashJSONObject(v.Interface().(map[string]interface{}))
+		return h, true, err
+	case jsonArrayType:
+		h, err := w.hashJSONArray(v.Interface().([]interface{}))
+		return h, true, err
+	}
+	return 0, false, nil
+}
+
+// hashJSONValue hashes a value in a map or slice hashed by hashJSON, like
+// visit does. It returns false if the value isn't included, which only
+// values hashed by visit can be.
+func (w *walker) hashJSONValue(x interface{}) (uint64, bool, error) {
+	switch x := x.(type) {
+	case nil:
+		// Like visit, hash nil map values like a zero int
+		return w.hash64(0), true, nil
+	case string:
+		return w.hashJSONString(x), true, nil
+	case float64:
+		return w.hash64(math.Float64bits(x)), true, nil
+	case bool:
+		if x {
+			return w.hash8(1), true, nil
+		}
+		return w.hash8(0), true, nil
+	}
+
+	// Nested maps and slices are visited with the cycle detection of
+	// visit once the walk is deep, so leave them to it.
+	switch x.(type) {
+	case map[string]interface{}, []interface{}:
+		if w.depth < startDetectingCyclesAfter {
+			w.depth++
+			h, _, err := w.hashJSON(reflect.ValueOf(x))
+			w.depth--
+			return h, true, err
+		}
+	}
+
+	v := reflect.ValueOf(x)
+	incl, err := w.selfIncluded(v)
+	if err != nil || !incl {
+		return 0, false, err
+	}
+	h, err := w.visit(v, visitOpts{})
+	return h, true, err
+}
+
+// hashJSONObject hashes m like the reflect.Map case of visitValue.
+func (w *walker) hashJSONObject(m map[string]interface{}) (uint64, error) {
+	hashes := make([]uint64, 0, len(m))
+	for k, x := range m {
+		vh, incl, err := w.hashJSONValue(x)
+		if err != nil {
+			return 0, err
+		}
+		if !incl {
+			continue
+		}
+		hashes = append(hashes, w.hashUpdateOrdered(w.hashJSONString(k), vh))
+	}
+	return w.foldMapEntries(0, hashes), nil
+}
+
+// hashJSONArray hashes s like visitSeq.
+func (w *walker) hashJSONArray(s []interface{}) (uint64, error) {
+	var h uint64
+	for _, x := range s {
+		if x == nil {
+			// Like visitElem, hash nil elements as a sentinel
+			h = w.hashUpdateOrdered(h, w.hashNil(emptyInterfaceType))
+			continue
+		}
+		current, incl, err := w.hashJSONValue(x)
+		if err != nil {
+			return 0, err
+		}
+		if incl {
+			h = w.hashUpdateOrdered(h, current)
+		}
+	}
+	return h, nil
+}
+
+// emptyInterfaceType is the type of the elements of a []interface{}, and
+// jsonObjectType and jsonArrayType are the types hashed by hashJSON.
+var (
+	emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+	jsonObjectType     = reflect.TypeOf(map[string]interface{}(nil))
+	jsonArrayType      = reflect.TypeOf([]interface{}(nil))
+)
+
+// hashJSONString hashes s like the reflect.String case of visitValue.
+func (w *walker) hashJSONString(s string) uint64 {
+	w.h.Reset()
+	// avoid allocating a new byte slice for the string
+	_, _ = w.h.Write(*(*[]byte)(unsafe.Pointer(&s)))
+	return w.h.Sum64()
+}
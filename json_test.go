@@ -0,0 +1,259 @@
+package hashstructure
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testJSON = `{
+	"id": "0001",
+	"type": "donut",
+	"name": "Cake",
+	"ppu": 0.55,
+	"available": true,
+	"discontinued": null,
+	"batters": {
+		"batter": [
+			{"id": "1001", "type": "Regular"},
+			{"id": "1002", "type": "Chocolate"},
+			{"id": "1003", "type": "Blueberry"}
+		]
+	},
+	"topping": [
+		{"id": "5001", "type": "None"},
+		{"id": "5002", "type": "Glazed"},
+		{"id": "5005", "type": "Sugar"}
+	],
+	"sizes": [6, 8, 12, -0.5, 1e300],
+	"tags": [],
+	"meta": {},
+	"same": [{"a": 1}, {"a": 1}],
+	"dupes": {"x": {"k": "v"}, "y": {"k": "v"}}
+}`
+
+// hashReflective hashes v like Hash, without the JSON fast path.
+func hashReflective(v interface{}, opts *HashOptions) (uint64, *CombineStats, error) {
+	var stats CombineStats
+	if opts == nil {
+		opts = &HashOptions{}
+	}
+	o := *opts
+	o.CombineStats = &stats
+	w, err := newWalker(&o)
+	if err != nil {
+		return 0, nil, err
+	}
+	w.reflectJSON = true
+	h, err := w.visit(reflect.ValueOf(v), visitOpts{Flags: visitFlagRoot})
+	if err != nil {
+		return 0, nil, err
+	}
+	return w.final(h), &stats, w.finish(nil)
+}
+
+func TestHash_jsonFastPath(t *testing.T) {
+	var tree interface{}
+	if err := json.Unmarshal([]byte(testJSON), &tree); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Value interface{}
+		Opts  *HashOptions
+	}{
+		{tree, nil},
+		{tree, &HashOptions{Version: 2}},
+		{map[string]interface{}{}, nil},
+		{[]interface{}{}, nil},
+		{map[string]interface{}(nil), nil},
+		{[]interface{}{nil, "", 0.0, false}, nil},
+		{map[string]interface{}{"a": []interface{}{nil, 1.0, nil}, "b": nil}, nil},
+		{[]interface{}{[]interface{}{[]interface{}{"deep"}}}, nil},
+
+		// Values that aren't from JSON are hashed by reflection
+		{
+			map[string]interface{}{
+				"int":     42,
+				"struct":  struct{ Name string }{"foo"},
+				"ptr":     new(string),
+				"typed":   map[string]string{"a": "b"},
+				"exclude": testSelfIncludable{Value: "a", Exclude: true},
+				"include": testSelfIncludable{Value: "b"},
+			},
+			nil,
+		},
+		{[]interface{}{testSelfIncludable{Value: "a", Exclude: true}, 1, (*int)(nil)}, nil},
+		{struct{ Tree interface{} }{tree}, nil},
+	}
+
+	for _, tc := range cases {
+		stats := &CombineStats{}
+		opts := &HashOptions{}
+		if tc.Opts != nil {
+			*opts = *tc.Opts
+		}
+		opts.CombineStats = stats
+		fast, err := Hash(tc.Value, opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Value, err)
+		}
+
+		expected, expectedStats, err := hashReflective(tc.Value, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Value, err)
+		}
+		if fast != expected {
+			t.Fatalf("bad, expected: %d\n\n%d\n\n%#v", expected, fast, tc.Value)
+		}
+		if *stats != *expectedStats {
+			t.Fatalf("bad stats, expected: %#v\n\n%#v\n\n%#v", expectedStats, stats, tc.Value)
+		}
+	}
+}
+
+func TestHash_jsonFastPathCycle(t *testing.T) {
+	m := map[string]interface{}{}
+	m["self"] = m
+	s := []interface{}{nil}
+	s[0] = s
+
+	for _, v := range []interface{}{m, s} {
+		fast, err := Hash(v, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		expected, _, err := hashReflective(v, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if fast != expected {
+			t.Fatalf("bad, expected: %d\n\n%d", expected, fast)
+		}
+	}
+}
+
+func BenchmarkHash_json(b *testing.B) {
+	var tree interface{}
+	if err := json.Unmarshal([]byte(testJSON), &tree); err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	b.Run("fast", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Hash(tree, nil); err != nil {
+				b.Fatalf("err: %s", err)
+			}
+		}
+	})
+	b.Run("reflect", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := hashReflective(tree, nil); err != nil {
+				b.Fatalf("err: %s", err)
+			}
+		}
+	})
+}
+
+func TestHash_preferJSON(t *testing.T) {
+	type Inner struct {
+		Count int `json:"count"`
+	}
+	type One struct {
+		Name  string            `json:"name"`
+		Tags  []string          `json:"tags"`
+		Inner Inner             `json:"inner"`
+		Meta  map[string]string `json:"meta"`
+	}
+	type Two struct {
+		Meta  map[string]string `json:"meta"`
+		Inner *Inner            `json:"inner"`
+		Tags  []string          `json:"tags"`
+		Name  string            `json:"name"`
+	}
+
+	opts := &HashOptions{PreferJSON: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// Field order and map order don't matter
+		{
+			One{Name: "foo", Tags: []string{"a"}, Inner: Inner{1}, Meta: map[string]string{"x": "1", "y": "2"}},
+			Two{Name: "foo", Tags: []string{"a"}, Inner: &Inner{1}, Meta: map[string]string{"y": "2", "x": "1"}},
+			opts,
+			true,
+		},
+		{
+			One{Name: "foo", Tags: []string{"a"}, Inner: Inner{1}},
+			Two{Name: "foo", Tags: []string{"a"}, Inner: &Inner{1}},
+			nil,
+			false,
+		},
+		{
+			One{Name: "foo", Inner: Inner{1}},
+			map[string]interface{}{"name": "foo", "tags": nil, "inner": map[string]int{"count": 1}, "meta": nil},
+			opts,
+			true,
+		},
+
+		// But the JSON does
+		{
+			One{Name: "foo", Tags: []string{"a", "b"}},
+			One{Name: "foo", Tags: []string{"b", "a"}},
+			opts,
+			false,
+		},
+		{One{Name: "foo"}, One{Name: "bar"}, opts, false},
+		{int64(1 << 62), int64(1<<62 + 1), opts, false},
+
+		// Both encode as null
+		{nil, map[string]int(nil), opts, true},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The hash is of the canonical JSON
+	h, err := Hash(Two{Name: "foo", Meta: map[string]string{"b": "<", "a": ""}}, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected, err := Hash(`{"inner":null,"meta":{"a":"","b":"<"},"name":"foo","tags":null}`, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if h != expected {
+		t.Fatalf("bad: %d != %d", h, expected)
+	}
+
+	// Values JSON can't encode fail
+	for _, v := range []interface{}{make(chan int), map[string]interface{}{"fn": func() {}}} {
+		_, err := Hash(v, opts)
+		if err == nil || !strings.Contains(err.Error(), "as JSON") {
+			t.Fatalf("bad: %v", err)
+		}
+	}
+}
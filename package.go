@@ -0,0 +1,59 @@
+package hashstructure
+
+import (
+	"reflect"
+	"sync"
+)
+
+var (
+	// packageHandlers is replaced rather than modified, so walkers can
+	// keep using the map they started with without holding the lock.
+	packageHandlersLock sync.Mutex
+	packageHandlers     map[string]func(reflect.Value) (uint64, error)
+)
+
+// RegisterPackage registers handler to hash all values whose type is
+// defined in the package with the import path pkgPath, as returned by
+// reflect.Type.PkgPath, instead of reflecting into them. This saves
+// listing every type of a package in InterfaceHandlers. Pointers to such
+// types are dereferenced first, and handler is never called with a nil
+// pointer. InterfaceHandlers take precedence over it. Registering a
+// package again replaces its handler, and registering a nil handler
+// removes it.
+func RegisterPackage(pkgPath string, handler func(v reflect.Value) (uint64, error)) {
+	packageHandlersLock.Lock()
+	defer packageHandlersLock.Unlock()
+
+	handlers := make(map[string]func(reflect.Value) (uint64, error), len(packageHandlers)+1)
+	for k, v := range packageHandlers {
+		handlers[k] = v
+	}
+	if handler == nil {
+		delete(handlers, pkgPath)
+	} else {
+		handlers[pkgPath] = handler
+	}
+	packageHandlers = handlers
+}
+
+// registeredPackages returns the handlers registered with RegisterPackage.
+func registeredPackages() map[string]func(reflect.Value) (uint64, error) {
+	packageHandlersLock.Lock()
+	defer packageHandlersLock.Unlock()
+	return packageHandlers
+}
+
+// packageHandler returns the handler registered for the package of the
+// type of v, if any.
+func (w *walker) packageHandler(v reflect.Value) func(reflect.Value) (uint64, error) {
+	if len(w.packageHandlers) == 0 || !v.IsValid() {
+		return nil
+	}
+
+	// Builtin and unnamed types have no package
+	pkg := v.Type().PkgPath()
+	if pkg == "" {
+		return nil
+	}
+	return w.packageHandlers[pkg]
+}
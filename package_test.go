@@ -0,0 +1,113 @@
+package hashstructure
+
+import (
+	"fmt"
+	"image"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegisterPackage(t *testing.T) {
+	var called []reflect.Type
+	RegisterPackage("image", func(v reflect.Value) (uint64, error) {
+		called = append(called, v.Type())
+		return Hash(v.Interface().(fmt.Stringer).String(), nil)
+	})
+	defer RegisterPackage("image", nil)
+
+	type Test struct {
+		Point *image.Point
+		Rect  image.Rectangle
+		Wait  time.Duration
+	}
+
+	cases := []struct {
+		Value  interface{}
+		Opts   *HashOptions
+		Called []reflect.Type
+		Same   interface{}
+	}{
+		// Every type of the package goes through the handler
+		{image.Pt(1, 2), nil, []reflect.Type{reflect.TypeOf(image.Point{})}, "(1,2)"},
+		{image.Rect(0, 0, 1, 1), nil, []reflect.Type{reflect.TypeOf(image.Rectangle{})}, "(0,0)-(1,1)"},
+		{&image.Point{X: 1, Y: 2}, nil, []reflect.Type{reflect.TypeOf(image.Point{})}, "(1,2)"},
+		{
+			Test{Point: &image.Point{X: 1, Y: 2}, Wait: time.Second},
+			nil,
+			[]reflect.Type{reflect.TypeOf(image.Point{}), reflect.TypeOf(image.Rectangle{})},
+			nil,
+		},
+
+		// Others don't, and neither do nil pointers
+		{time.Second, nil, nil, int64(time.Second)},
+		{(*image.Point)(nil), nil, nil, nil},
+
+		// InterfaceHandlers take precedence
+		{
+			image.Pt(1, 2),
+			&HashOptions{InterfaceHandlers: []InterfaceHandler{{
+				Iface: reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+				Fn:    func(reflect.Value) (uint64, error) { return 42, nil },
+			}}},
+			nil,
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		called = nil
+		h, err := Hash(tc.Value, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Value, err)
+		}
+		if !reflect.DeepEqual(called, tc.Called) {
+			t.Fatalf("bad, expected calls: %v\n\n%v\n\n%#v", tc.Called, called, tc.Value)
+		}
+
+		if tc.Same != nil {
+			expected, err := Hash(tc.Same, nil)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if h != expected {
+				t.Fatalf("bad, expected: %#v to hash like %#v", tc.Value, tc.Same)
+			}
+		}
+	}
+
+	// Removing the handler walks the types again
+	RegisterPackage("image", nil)
+	called = nil
+	h, err := Hash(image.Pt(1, 2), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected, err := Hash("(1,2)", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(called) != 0 || h == expected {
+		t.Fatalf("bad: handler still used after it was removed")
+	}
+}
+
+func TestRegisterPackage_bulkNumbers(t *testing.T) {
+	RegisterPackage("time", func(v reflect.Value) (uint64, error) {
+		return uint64(v.Interface().(time.Duration) / time.Second), nil
+	})
+	defer RegisterPackage("time", nil)
+
+	// Numbers of a registered package aren't hashed in bulk
+	one, err := Hash([]time.Duration{time.Second, 2 * time.Second}, &HashOptions{BulkNumbers: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	two, err := Hash([]time.Duration{time.Second + 1, 2*time.Second + 1}, &HashOptions{BulkNumbers: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if one != two {
+		t.Fatal("bad: handler not used with BulkNumbers")
+	}
+}
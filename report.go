@@ -0,0 +1,109 @@
+package hashstructure
+
+import (
+	"reflect"
+	"strings"
+)
+
+// HashReport hashes a and b with the given options and returns a report of
+// which of their values hash the same, to find out why they differ. The
+// report has a line for every value, indented by its nesting, such as:
+//
+//	! (root)
+//	  = .Name
+//	  ! .Inner
+//	    ! .Enabled
+//	  - .Tags
+//
+// Each line starts with "=" if the value hashes the same in both, "!" if
+// it differs, "-" if it only exists in a and "+" if it only exists in b.
+// The values within a value that hashes the same aren't listed. Elements
+// of sets are compared by their index, so they can be reported as
+// different even if the set as a whole isn't. Entries of maps are listed
+// in the order of the hashes of their keys.
+func HashReport(a, b interface{}, opts *HashOptions) (string, error) {
+	root := &reportNode{segment: "(root)"}
+	for i, v := range []interface{}{a, b} {
+		w, err := newWalker(opts)
+		if err != nil {
+			return "", err
+		}
+		w.trackPath = true
+		w.onVisit = func(path []string, h uint64) {
+			root.child(path).hashes[i] = &h
+		}
+		w.jsonFast = w.jsonFastPath()
+
+		_, err = w.visit(reflect.ValueOf(v), visitOpts{Flags: visitFlagRoot})
+		if err = w.finish(err); err != nil {
+			return "", err
+		}
+	}
+
+	var report strings.Builder
+	root.write(&report, 0)
+	return report.String(), nil
+}
+
+// reportNode is a value in the report of HashReport, with its hash in each
+// of the two hashed values, if it exists in them.
+type reportNode struct {
+	segment  string
+	hashes   [2]*uint64
+	children []*reportNode
+	index    map[string]*reportNode
+}
+
+// child returns the node at the given path below n, adding it if needed.
+func (n *reportNode) child(path []string) *reportNode {
+	for _, segment := range path {
+		c, ok := n.index[segment]
+		if !ok {
+			if n.index == nil {
+				n.index = make(map[string]*reportNode)
+			}
+			c = &reportNode{segment: segment}
+			n.index[segment] = c
+			n.children = append(n.children, c)
+		}
+		n = c
+	}
+	return n
+}
+
+// mark returns the mark of the report line of n.
+func (n *reportNode) mark() string {
+	switch one, two := n.hashes[0], n.hashes[1]; {
+	case one != nil && two != nil:
+		if *one == *two {
+			return "="
+		}
+		return "!"
+	case one != nil:
+		return "-"
+	case two != nil:
+		return "+"
+	}
+
+	// Values that aren't hashed on their own, like slices nested in
+	// slices with Iterative, are the same if all their values are.
+	for _, c := range n.children {
+		if c.mark() != "=" {
+			return "!"
+		}
+	}
+	return "="
+}
+
+// write writes the report lines of n and its children to b.
+func (n *reportNode) write(b *strings.Builder, depth int) {
+	mark := n.mark()
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(mark + " " + n.segment + "\n")
+	if mark == "=" {
+		return
+	}
+	for _, c := range n.children {
+		c.write(b, depth+1)
+	}
+}
@@ -0,0 +1,92 @@
+package hashstructure
+
+import (
+	"testing"
+)
+
+func TestHashReport(t *testing.T) {
+	type Inner struct {
+		Enabled bool
+		Port    int
+	}
+
+	type Test struct {
+		Name  string
+		Inner Inner
+		Tags  []string
+		Meta  map[string]interface{}
+	}
+
+	cases := []struct {
+		One, Two interface{}
+		Expected string
+	}{
+		{
+			Test{Name: "foo", Inner: Inner{Enabled: true, Port: 80}},
+			Test{Name: "foo", Inner: Inner{Enabled: false, Port: 80}},
+			"! (root)\n" +
+				"  = .Name\n" +
+				"  ! .Inner\n" +
+				"    ! .Enabled\n" +
+				"    = .Port\n" +
+				"  = .Tags\n" +
+				"  = .Meta\n",
+		},
+		{
+			Test{Name: "foo", Tags: []string{"a", "b"}},
+			Test{Name: "foo", Tags: []string{"a", "c", "d"}},
+			"! (root)\n" +
+				"  = .Name\n" +
+				"  = .Inner\n" +
+				"  ! .Tags\n" +
+				"    = [0]\n" +
+				"    ! [1]\n" +
+				"    + [2]\n" +
+				"  = .Meta\n",
+		},
+		{
+			Test{Meta: map[string]interface{}{"a": 1, "b": 2}},
+			Test{Meta: map[string]interface{}{"a": 1, "c": 2}},
+			"! (root)\n" +
+				"  = .Name\n" +
+				"  = .Inner\n" +
+				"  = .Tags\n" +
+				"  ! .Meta\n" +
+				"    - [b]\n" +
+				"    = [a]\n" +
+				"    + [c]\n",
+		},
+		{
+			Test{Name: "foo"},
+			Test{Name: "foo"},
+			"= (root)\n",
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := HashReport(tc.One, tc.Two, nil)
+		if err != nil {
+			t.Fatalf("Failed to report %#v and %#v: %s", tc.One, tc.Two, err)
+		}
+		if actual != tc.Expected {
+			t.Fatalf("bad report of %#v and %#v\n\ngot:\n%s\nexpected:\n%s", tc.One, tc.Two, actual, tc.Expected)
+		}
+	}
+}
+
+func TestHashReport_iterative(t *testing.T) {
+	one := [][]int{{1, 2}, {3}}
+	two := [][]int{{1, 2}, {4}}
+
+	actual, err := HashReport(one, two, &HashOptions{Iterative: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := "! (root)\n" +
+		"  = [0]\n" +
+		"  ! [1]\n" +
+		"    ! [0]\n"
+	if actual != expected {
+		t.Fatalf("bad report\n\ngot:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
@@ -0,0 +1,57 @@
+package hashstructure
+
+// rollingBase is the base of the polynomial of RollingHasher. It is the
+// 64-bit FNV prime, which is odd, so each byte affects every bit above it.
+const rollingBase uint64 = 0x100000001b3
+
+// RollingHasher computes the hash of a fixed-size window of bytes that
+// slides over a buffer, such as to find chunks of a file that are already
+// known. Moving the window by one byte with Roll takes constant time, and
+// gives the same hash as computing the hash of the new window from
+// scratch.
+//
+// The hash is a polynomial over the bytes of the window, so it is fast but
+// not suited to adversarial input. Only Domain and Version of the options
+// apply to it, and they are folded into Sum64 like with Hash.
+type RollingHasher struct {
+	w   *walker
+	err error
+	h   uint64
+
+	// pow is rollingBase to the power of the size of the window, which
+	// removes the byte leaving the window.
+	pow uint64
+}
+
+// NewRollingHasher returns a RollingHasher for the initial window. If opts
+// is nil, then default options will be used. If the options are invalid,
+// Sum64 returns zero.
+func NewRollingHasher(window []byte, opts *HashOptions) *RollingHasher {
+	w, err := newWalker(opts)
+	r := &RollingHasher{w: w, err: err, pow: 1}
+	for _, b := range window {
+		r.h = r.h*rollingBase + rollingByte(b)
+		r.pow *= rollingBase
+	}
+	return r
+}
+
+// Roll moves the window by one byte: in is added at the end of the window
+// and out, which must be the first byte of the window, is removed.
+func (r *RollingHasher) Roll(in, out byte) {
+	r.h = r.h*rollingBase + rollingByte(in) - r.pow*rollingByte(out)
+}
+
+// Sum64 returns the hash of the current window.
+func (r *RollingHasher) Sum64() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	return r.w.final(r.h)
+}
+
+// rollingByte returns the term of b in the polynomial. It is never zero,
+// so windows of zero bytes don't all hash as zero.
+func rollingByte(b byte) uint64 {
+	return uint64(b) + 1
+}
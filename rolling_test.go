@@ -0,0 +1,59 @@
+package hashstructure
+
+import (
+	"testing"
+)
+
+func TestRollingHasher(t *testing.T) {
+	buf := make([]byte, 1024)
+	for i := range buf {
+		buf[i] = byte(i * 7 % 251)
+	}
+	// Some runs of the same byte, including zeros
+	copy(buf[100:], make([]byte, 64))
+	copy(buf[300:], []byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"))
+
+	for _, opts := range []*HashOptions{nil, {Domain: "chunks"}} {
+		for _, size := range []int{1, 2, 16, 64} {
+			r := NewRollingHasher(buf[:size], opts)
+			seen := make(map[uint64]struct{})
+			windows := make(map[string]struct{})
+			for i := 0; ; i++ {
+				expected := NewRollingHasher(buf[i:i+size], opts).Sum64()
+				if h := r.Sum64(); h != expected {
+					t.Fatalf("bad: window %d of size %d, expected: %d, got: %d", i, size, expected, h)
+				}
+				if expected == 0 {
+					t.Fatalf("zero hash: window %d of size %d", i, size)
+				}
+				seen[expected] = struct{}{}
+				windows[string(buf[i:i+size])] = struct{}{}
+
+				if i+size == len(buf) {
+					break
+				}
+				r.Roll(buf[i+size], buf[i])
+			}
+
+			// Distinct windows hash differently
+			if len(seen) != len(windows) {
+				t.Fatalf("bad: %d distinct hashes for %d distinct windows of size %d", len(seen), len(windows), size)
+			}
+		}
+	}
+
+	// The domain separates the hashes
+	one := NewRollingHasher(buf[:16], nil).Sum64()
+	two := NewRollingHasher(buf[:16], &HashOptions{Domain: "chunks"}).Sum64()
+	if one == two {
+		t.Fatal("bad: domain not folded in")
+	}
+}
+
+func TestRollingHasher_invalidOptions(t *testing.T) {
+	r := NewRollingHasher([]byte("foo"), &HashOptions{NormalizeTime: true, TimeZoneSensitive: true})
+	r.Roll('b', 'f')
+	if r.Sum64() != 0 {
+		t.Fatal("expected zero hash")
+	}
+}
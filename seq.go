@@ -0,0 +1,369 @@
+package hashstructure
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// seqFrame is the state of hashing a slice or array.
+type seqFrame struct {
+	v     reflect.Value
+	set   bool
+	i     int
+	h     uint64
+	texts []string
+
+	// hashes are the element hashes of a set. Once the frame is done they
+	// are sorted, so they don't depend on the order of the elements.
+	hashes []uint64
+
+	// elemOpts are the options the elements are visited with. The
+	// elements of a set field keep the struct holding it, so an
+	// IncludableMap struct filters the maps in the set.
+	elemOpts visitOpts
+
+	// key is the cycle key of a nested frame of an iterative walk, if
+	// tracked is true.
+	key     cycleKey
+	tracked bool
+}
+
+// visitSeq hashes the slice or array v, as a set if set is true, visiting
+// its elements with elemOpts.
+func (w *walker) visitSeq(v reflect.Value, set bool, elemOpts visitOpts) (uint64, error) {
+	if !set && w.bulkNumbers && w.bulkElem(v.Type().Elem()) {
+		return w.hashNumbers(v), nil
+	}
+	if w.iterative {
+		return w.visitSeqIterative(v, set, elemOpts)
+	}
+	return w.visitSeqFrame(&seqFrame{v: v, set: set, elemOpts: elemOpts})
+}
+
+// visitRevSet hashes the slice v for hash:"revset", so that it hashes the
+// same as its reversal. The ordered hashes of the elements forwards and
+// backwards are combined with the commutative set combiner.
+func (w *walker) visitRevSet(v reflect.Value) (uint64, error) {
+	f := &seqFrame{v: v}
+	var hashes []uint64
+	for {
+		elem, ok, err := w.seqNext(f)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+
+		current, err := w.visitElem(elem, visitOpts{})
+		if err != nil {
+			w.popPath()
+			return 0, err
+		}
+		w.seqAdd(f, current)
+		hashes = append(hashes, current)
+	}
+
+	var reverse uint64
+	for i := len(hashes) - 1; i >= 0; i-- {
+		reverse = w.hashUpdateOrdered(reverse, hashes[i])
+	}
+
+	if w.canonical {
+		// Use whichever direction sorts first
+		reversed := make([]string, len(f.texts))
+		for i, text := range f.texts {
+			reversed[len(reversed)-1-i] = text
+		}
+		w.text = canonicalList("revset[", f.texts, "]", false)
+		if text := canonicalList("revset[", reversed, "]", false); text < w.text {
+			w.text = text
+		}
+	}
+	return w.hashUpdateSet(w.hashUpdateSet(0, f.h), reverse), nil
+}
+
+// visitSeqFrame hashes all the elements of f recursively.
+func (w *walker) visitSeqFrame(f *seqFrame) (uint64, error) {
+	for {
+		elem, ok, err := w.seqNext(f)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return w.seqDone(f), nil
+		}
+
+		current, err := w.visitElem(elem, f.elemOpts)
+		if err != nil {
+			w.popPath()
+			return 0, err
+		}
+		w.seqAdd(f, current)
+	}
+}
+
+// visitSeqIterative hashes the slice or array v like visitSeq, but descends
+// into nested slices and arrays using an explicit stack of frames. Each
+// nested frame counts as a level of depth for cycle detection.
+func (w *walker) visitSeqIterative(v reflect.Value, set bool, elemOpts visitOpts) (uint64, error) {
+	depth := len(w.path)
+	stack := []*seqFrame{{v: v, set: set, elemOpts: elemOpts}}
+
+	// pop removes the top frame from the stack
+	pop := func() {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if len(stack) > 0 {
+			if f.tracked {
+				w.leaveCycle(f.key)
+			}
+			w.depth--
+		}
+	}
+
+	for {
+		f := stack[len(stack)-1]
+		elem, ok, err := w.seqNext(f)
+		if err != nil {
+			for len(stack) > 0 {
+				pop()
+			}
+			w.truncatePath(depth)
+			return 0, err
+		}
+
+		if !ok {
+			// Done with this frame, so add it to its parent
+			h := w.seqDone(f)
+			pop()
+			if len(stack) == 0 {
+				return h, nil
+			}
+			w.seqAdd(stack[len(stack)-1], h)
+			continue
+		}
+
+		if nested, ok := w.nestedSeq(elem); ok {
+			if w.bulkNumbers && w.bulkElem(nested.Type().Elem()) {
+				w.seqAdd(f, w.hashNumbers(nested))
+				continue
+			}
+
+			child := &seqFrame{v: nested}
+			w.depth++
+			if w.depth > startDetectingCyclesAfter {
+				var cycle bool
+				if child.key, child.tracked, cycle = w.enterCycle(nested); cycle {
+					w.depth--
+					w.seqAdd(f, w.hashCycle(nested.Type()))
+					continue
+				}
+			}
+			stack = append(stack, child)
+			continue
+		}
+
+		current, err := w.visitElem(elem, f.elemOpts)
+		if err != nil {
+			for len(stack) > 0 {
+				pop()
+			}
+			w.truncatePath(depth)
+			return 0, err
+		}
+		w.seqAdd(f, current)
+	}
+}
+
+// seqNext returns the next element of f to hash, skipping elements that
+// exclude themselves. It returns false when there are no more elements.
+// The path of the returned element is pushed until it is added.
+func (w *walker) seqNext(f *seqFrame) (reflect.Value, bool, error) {
+	for ; f.i < f.v.Len(); f.i++ {
+		elem := f.v.Index(f.i)
+		incl, err := w.selfIncluded(elem)
+		if err != nil {
+			return reflect.Value{}, false, err
+		}
+		if !incl {
+			continue
+		}
+
+		if w.trackPath {
+			w.pushPath("[" + strconv.Itoa(f.i) + "]")
+		}
+		f.i++
+		return elem, true, nil
+	}
+	return reflect.Value{}, false, nil
+}
+
+// seqAdd adds the hash of the element returned by seqNext to f.
+func (w *walker) seqAdd(f *seqFrame, current uint64) {
+	w.popPath()
+	if w.canonical {
+		f.texts = append(f.texts, w.text)
+	}
+
+	if f.set {
+		f.hashes = append(f.hashes, current)
+	} else {
+		f.h = w.hashUpdateOrdered(f.h, current)
+	}
+}
+
+// seqDone returns the hash of f once all its elements are added. The
+// elements of a set are combined in the order of their sorted hashes.
+func (w *walker) seqDone(f *seqFrame) uint64 {
+	if f.set {
+		sort.Slice(f.hashes, func(i, j int) bool { return f.hashes[i] < f.hashes[j] })
+		for _, h := range f.hashes {
+			f.h = w.hashUpdateSet(f.h, h)
+		}
+	}
+
+	if w.canonical {
+		if f.set {
+			w.text = canonicalList("set[", f.texts, "]", true)
+		} else {
+			w.text = canonicalList("[", f.texts, "]", false)
+		}
+	}
+	return f.h
+}
+
+// nestedSeq returns the slice or array that visitElem would hash for v, if
+// any, by dereferencing v the same way.
+func (w *walker) nestedSeq(v reflect.Value) (reflect.Value, bool) {
+	// PreHash may handle any nested value, so leave it to visitElem
+	if w.preHash != nil {
+		return reflect.Value{}, false
+	}
+
+	if !w.zeronil && v.Kind() == reflect.Ptr && v.IsNil() {
+		return reflect.Value{}, false
+	}
+
+	for {
+		if v.Kind() == reflect.Interface {
+			// The type of the value is folded once it is hashed, so
+			// leave it to visitElem.
+			if w.includeInterfaceType && !v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+			continue
+		}
+
+		if p, ok := w.addressed(v); ok && w.customHandled(p) {
+			return reflect.Value{}, false
+		}
+		if w.customHandled(v) {
+			return reflect.Value{}, false
+		}
+
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		case reflect.Slice, reflect.Array:
+			return v, true
+		default:
+			return reflect.Value{}, false
+		}
+	}
+}
+
+var (
+	selfIncludableType = reflect.TypeOf((*SelfIncludable)(nil)).Elem()
+	goStringerType     = reflect.TypeOf((*fmt.GoStringer)(nil)).Elem()
+)
+
+// bulkElem returns whether slices and arrays with elements of type t can be
+// hashed with hashNumbers, which requires that no option changes how the
+// elements are hashed.
+func (w *walker) bulkElem(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+	default:
+		return false
+	}
+
+	if w.numericCoercion || w.canonical || w.preHash != nil || w.includeInterfaceType || w.rejectFloats ||
+		w.roundFloatsToInt {
+		return false
+	}
+	if _, ok := w.defaultPrototypes[t]; ok {
+		return false
+	}
+	if t.Implements(selfIncludableType) || (w.useGoStringer && t.Implements(goStringerType)) {
+		return false
+	}
+	if w.errorsAsString && (t.Implements(errorType) || reflect.PtrTo(t).Implements(errorType)) {
+		return false
+	}
+	if t.PkgPath() != "" && w.packageHandlers[t.PkgPath()] != nil {
+		return false
+	}
+	if w.stdlibCanonicalization && t == durationType {
+		return false
+	}
+	for _, handler := range w.interfaceHandlers {
+		if t.Implements(handler.Iface) {
+			return false
+		}
+	}
+	return true
+}
+
+// hashNumbers hashes the slice or array of numbers v by writing all its
+// elements at once, followed by its length.
+func (w *walker) hashNumbers(v reflect.Value) uint64 {
+	var chunk [4096]byte
+	size := int(v.Type().Elem().Size())
+	kind := v.Type().Elem().Kind()
+
+	w.h.Reset()
+	n := 0
+	for i := 0; i < v.Len(); i++ {
+		if n+size > len(chunk) {
+			_, _ = w.h.Write(chunk[:n])
+			n = 0
+		}
+
+		var bits uint64
+		switch kind {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			bits = uint64(v.Index(i).Int())
+		case reflect.Float32:
+			bits = uint64(math.Float32bits(float32(v.Index(i).Float())))
+		case reflect.Float64:
+			bits = math.Float64bits(v.Index(i).Float())
+		default:
+			bits = v.Index(i).Uint()
+		}
+
+		switch size {
+		case 1:
+			chunk[n] = byte(bits)
+		case 2:
+			w.order.PutUint16(chunk[n:], uint16(bits))
+		case 4:
+			w.order.PutUint32(chunk[n:], uint32(bits))
+		default:
+			w.order.PutUint64(chunk[n:], bits)
+		}
+		n += size
+	}
+	_, _ = w.h.Write(chunk[:n])
+
+	return w.hashUpdateOrdered(w.h.Sum64(), uint64(v.Len()))
+}
@@ -0,0 +1,285 @@
+package hashstructure
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+	"syscall"
+	"testing"
+)
+
+func TestHash_iterative(t *testing.T) {
+	type Test struct {
+		Name    string
+		Friends []string `hash:"set"`
+		Nested  [][]interface{}
+	}
+
+	RegisterPackage("sort", func(v reflect.Value) (uint64, error) {
+		return uint64(v.Len()), nil
+	})
+	defer RegisterPackage("sort", nil)
+
+	cases := []struct {
+		V    interface{}
+		Opts HashOptions
+	}{
+		{[]interface{}{1, nil, "foo"}, HashOptions{}},
+		{[][]int{{1, 2}, {}, {3}}, HashOptions{}},
+		{[2][]*int{{nil, new(int)}, nil}, HashOptions{}},
+		{[]interface{}{[]interface{}{[]string{"foo"}}, map[string][]int{"bar": {1}}}, HashOptions{}},
+		{Test{Name: "foo", Friends: []string{"bar", "baz"}, Nested: [][]interface{}{{1, []int{2}}}}, HashOptions{}},
+
+		// Nested values handled by a registered package
+		{[]sort.IntSlice{{1, 2}, {3}}, HashOptions{}},
+		{[]sort.IntSlice{{1, 2}, {3}}, HashOptions{BulkNumbers: true}},
+
+		// Nested slices hashed by their Error method
+		{[]error{testMultiError{"foo", "bar"}}, HashOptions{ErrorsAsString: true}},
+		{[]testMultiError{{"foo"}, nil}, HashOptions{ErrorsAsString: true}},
+	}
+
+	for _, tc := range cases {
+		recursive, err := Hash(tc.V, &tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.V, err)
+		}
+		opts := tc.Opts
+		opts.Iterative = true
+		iterative, err := Hash(tc.V, &opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.V, err)
+		}
+
+		if recursive != iterative {
+			t.Fatalf("expected iterative hash to match: %d != %d\n\n%#v", recursive, iterative, tc.V)
+		}
+	}
+}
+
+func TestHash_iterativeDeep(t *testing.T) {
+	// Deep enough that recursing would need a very large stack
+	var v interface{} = []interface{}{"leaf"}
+	for i := 0; i < 1000000; i++ {
+		v = []interface{}{v}
+	}
+
+	h, err := Hash(v, &HashOptions{Iterative: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if h == 0 {
+		t.Fatal("zero hash")
+	}
+}
+
+func TestHash_arrayLength(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// Arrays and slices with the same elements hash the same
+		{[3]int{1, 2, 3}, []int{1, 2, 3}, nil, true},
+		{[0]int{}, []int{}, nil, true},
+		{[2][2]string{{"a"}, {"b"}}, [][]string{{"a", ""}, {"b", ""}}, nil, true},
+		{[3]int{1, 2, 3}, []int{1, 2, 3}, &HashOptions{BulkNumbers: true}, true},
+		{[3]int{1, 2, 3}, []int{1, 2, 3}, &HashOptions{Iterative: true}, true},
+
+		// Trailing zero elements change the hash
+		{[2]int{1, 0}, [3]int{1, 0, 0}, nil, false},
+		{[1]int{0}, [2]int{0, 0}, nil, false},
+		{[2]int{1, 0}, [3]int{1, 0, 0}, &HashOptions{BulkNumbers: true}, false},
+		{[2]int{1, 0}, [3]int{1, 0, 0}, &HashOptions{Iterative: true}, false},
+		{[1]struct{}{}, [2]struct{}{}, nil, false},
+		{[2]string{"a"}, [3]string{"a"}, nil, false},
+		{[]int{1, 0}, []int{1, 0, 0}, nil, false},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// Every length of an array of zeros hashes differently
+	seen := make(map[uint64]int)
+	for n := 0; n <= 16; n++ {
+		h, err := Hash(reflect.New(reflect.ArrayOf(n, reflect.TypeOf(0))).Elem().Interface(), nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if m, ok := seen[h]; ok {
+			t.Fatalf("bad: arrays of %d and %d zeros hash the same", m, n)
+		}
+		seen[h] = n
+	}
+}
+
+func TestSeqFrame_sortedSetHashes(t *testing.T) {
+	orders := [][]string{
+		{"foo", "bar", "baz", "bar"},
+		{"bar", "bar", "baz", "foo"},
+		{"baz", "bar", "foo", "bar"},
+	}
+
+	var expected []uint64
+	var expectedHash uint64
+	for i, order := range orders {
+		w, err := newWalker(nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		f := &seqFrame{v: reflect.ValueOf(order), set: true}
+		h, err := w.visitSeqFrame(f)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if len(f.hashes) != len(order) {
+			t.Fatalf("expected %d element hashes, got %d", len(order), len(f.hashes))
+		}
+		if !sort.SliceIsSorted(f.hashes, func(i, j int) bool { return f.hashes[i] < f.hashes[j] }) {
+			t.Fatalf("element hashes not sorted: %v", f.hashes)
+		}
+
+		if i == 0 {
+			expected, expectedHash = f.hashes, h
+			continue
+		}
+		if !reflect.DeepEqual(f.hashes, expected) || h != expectedHash {
+			t.Fatalf("element hashes differ for %v: %v != %v", order, f.hashes, expected)
+		}
+	}
+}
+
+func TestHash_bulkNumbers(t *testing.T) {
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		{[]int64{1, 2, 3}, []int64{1, 2, 3}, &HashOptions{BulkNumbers: true}, true},
+		{[]int64{1, 2, 3}, []int64{3, 2, 1}, &HashOptions{BulkNumbers: true}, false},
+		{[]int8{0}, []int8{0, 0}, &HashOptions{BulkNumbers: true}, false},
+		{[]float64{1.5}, [1]float64{1.5}, &HashOptions{BulkNumbers: true}, true},
+		{[]uint16{1, 2}, []uint16{1, 2}, &HashOptions{BulkNumbers: true, ByteOrder: binary.BigEndian}, true},
+		{[]int64{1, 2, 3}, []int64{1, 2, 3}, nil, true},
+		{
+			struct {
+				Values []int `hash:"set"`
+			}{[]int{1, 2}},
+			struct {
+				Values []int `hash:"set"`
+			}{[]int{2, 1}},
+			&HashOptions{BulkNumbers: true},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+
+	// The bulk hash is pinned, so it stays the same across versions, and
+	// differs from hashing each element.
+	v := [][]float64{{1.5, -2, 1e10}, {0}}
+	bulk, err := Hash(v, &HashOptions{BulkNumbers: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if bulk != 16915045193377460016 {
+		t.Fatalf("bad: %d", bulk)
+	}
+	perElement, err := Hash(v, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if bulk == perElement {
+		t.Fatal("bulk hash should differ from the per-element hash")
+	}
+
+	// The iterative walk hashes nested slices the same way
+	iterative, err := Hash(v, &HashOptions{BulkNumbers: true, Iterative: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if iterative != bulk {
+		t.Fatalf("bad: %d != %d", iterative, bulk)
+	}
+
+	// Options that change how elements are hashed turn it off
+	coerced, err := Hash(v, &HashOptions{BulkNumbers: true, NumericCoercion: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected, err := Hash(v, &HashOptions{NumericCoercion: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if coerced != expected {
+		t.Fatalf("bad: %d != %d", coerced, expected)
+	}
+
+	// Numbers that are errors are hashed by their message under
+	// ErrorsAsString
+	errnos := []syscall.Errno{syscall.ENOENT, syscall.EACCES}
+	bulkErrnos, err := Hash(errnos, &HashOptions{BulkNumbers: true, ErrorsAsString: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected, err = Hash(errnos, &HashOptions{ErrorsAsString: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if bulkErrnos != expected {
+		t.Fatalf("bad: %d != %d", bulkErrnos, expected)
+	}
+}
+
+func BenchmarkHash_float64s(b *testing.B) {
+	v := make([]float64, 1000000)
+	for i := range v {
+		v[i] = float64(i) / 3
+	}
+
+	for _, bulk := range []bool{false, true} {
+		b.Run(fmt.Sprintf("bulk=%t", bulk), func(b *testing.B) {
+			opts := &HashOptions{BulkNumbers: bulk}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Hash(v, opts); err != nil {
+					b.Fatalf("err: %s", err)
+				}
+			}
+		})
+	}
+}
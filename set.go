@@ -0,0 +1,53 @@
+package hashstructure
+
+import (
+	"reflect"
+)
+
+// SetHasher computes the hash of a set of values that are added one at a
+// time, so a large set can be hashed without holding all of it in memory.
+// The result doesn't depend on the order that elements are added in, but
+// adding the same element twice gives a different result than adding it
+// once.
+//
+// A SetHasher uses the Hasher of its options, so the same *HashOptions
+// value cannot be used concurrently with it.
+type SetHasher struct {
+	w   *walker
+	err error
+	sum uint64
+	n   uint64
+}
+
+// NewSetHasher returns a SetHasher for the given options. If opts is nil,
+// then default options will be used. If the options are invalid, every
+// call to AddElement returns the error and Sum64 returns zero.
+func NewSetHasher(opts *HashOptions) *SetHasher {
+	w, err := newWalker(opts)
+	return &SetHasher{w: w, err: err}
+}
+
+// AddElement adds v to the set. Like with HashAll, v is hashed as a
+// top-level value, so options such as OnlyFields apply to it.
+func (s *SetHasher) AddElement(v interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	h, err := s.w.visit(reflect.ValueOf(v), visitOpts{Flags: visitFlagRoot})
+	if err := s.w.finish(err); err != nil {
+		return err
+	}
+
+	s.sum = s.w.hashUpdateSet(s.sum, h)
+	s.n++
+	return nil
+}
+
+// Sum64 returns the hash of all the elements added so far.
+func (s *SetHasher) Sum64() uint64 {
+	if s.err != nil {
+		return 0
+	}
+	return s.w.final(s.w.hashUpdateOrdered(s.n, s.sum))
+}
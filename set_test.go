@@ -0,0 +1,67 @@
+package hashstructure
+
+import (
+	"testing"
+)
+
+func TestSetHasher(t *testing.T) {
+	cases := []struct {
+		One, Two []interface{}
+		Match    bool
+	}{
+		{
+			[]interface{}{"foo", "bar", 42},
+			[]interface{}{42, "bar", "foo"},
+			true,
+		},
+
+		{
+			[]interface{}{"foo", "foo"},
+			[]interface{}{"foo"},
+			false,
+		},
+
+		{
+			[]interface{}{"foo", "foo", "bar", "bar"},
+			[]interface{}{},
+			false,
+		},
+
+		{
+			[]interface{}{"foo", "foo", "bar"},
+			[]interface{}{"foo", "bar", "bar"},
+			false,
+		},
+
+		{
+			[]interface{}{"foo", "bar", "foo"},
+			[]interface{}{"foo", "foo", "bar"},
+			true,
+		},
+	}
+
+	sum := func(vs []interface{}) uint64 {
+		s := NewSetHasher(nil)
+		for _, v := range vs {
+			if err := s.AddElement(v); err != nil {
+				t.Fatalf("Failed to add %#v: %s", v, err)
+			}
+		}
+		return s.Sum64()
+	}
+
+	for _, tc := range cases {
+		one := sum(tc.One)
+		two := sum(tc.Two)
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v", tc.Match, tc.One, tc.Two)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package hashstructure
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FieldHashes returns the hash of each field of the struct v, as combined
+// into the hash of v by Hash with the same options, by the name of the
+// field. Fields that aren't hashed, such as ignored fields, are omitted,
+// and the fields of flattened embedded structs are included by their own
+// names. v must be a struct or a pointer to one.
+func FieldHashes(v interface{}, opts *HashOptions) (map[string]uint64, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("hashstructure: FieldHashes requires a struct, got %s", rv.Kind())
+	}
+
+	w, err := newWalker(opts)
+	if err != nil {
+		return nil, err
+	}
+	w.fieldHashes = make(map[string]uint64)
+	_, err = w.visit(rv, visitOpts{Flags: visitFlagRoot})
+	if err = w.finish(err); err != nil {
+		return nil, err
+	}
+	return w.fieldHashes, nil
+}
+
+// Snapshot holds the field hashes of a struct at one point in time, to
+// find out which of its fields changed since.
+type Snapshot struct {
+	opts   *HashOptions
+	fields map[string]uint64
+}
+
+// NewSnapshot returns a snapshot of the field hashes of the struct v, as
+// returned by FieldHashes. The options are used for the snapshot and for
+// every call to Changed, so they must not be modified afterwards.
+func NewSnapshot(v interface{}, opts *HashOptions) (*Snapshot, error) {
+	fields, err := FieldHashes(v, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{opts: opts, fields: fields}, nil
+}
+
+// Changed returns the sorted names of the fields of the struct v that hash
+// differently than in the snapshot, including fields that are only hashed
+// in one of them.
+func (s *Snapshot) Changed(v interface{}) ([]string, error) {
+	fields, err := FieldHashes(v, s.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for name, h := range fields {
+		if old, ok := s.fields[name]; !ok || old != h {
+			changed = append(changed, name)
+		}
+	}
+	for name := range s.fields {
+		if _, ok := fields[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
@@ -0,0 +1,112 @@
+package hashstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldHashes(t *testing.T) {
+	type Base struct {
+		ID int
+	}
+
+	type Test struct {
+		Base
+		Name  string
+		Tags  []string `hash:"set"`
+		Cache string   `hash:"ignore"`
+	}
+
+	v := Test{Base: Base{ID: 1}, Name: "foo", Tags: []string{"a", "b"}}
+	fields, err := FieldHashes(v, &HashOptions{FlattenEmbedded: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	if len(names) != 3 || fields["ID"] == 0 || fields["Name"] == 0 || fields["Tags"] == 0 {
+		t.Fatalf("bad field hashes: %#v", fields)
+	}
+
+	ptr, err := FieldHashes(&v, &HashOptions{FlattenEmbedded: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(fields, ptr) {
+		t.Fatalf("expected the same field hashes for a pointer:\n\n%#v\n\n%#v", fields, ptr)
+	}
+
+	if _, err := FieldHashes([]string{"foo"}, nil); err == nil {
+		t.Fatal("expected error for a non-struct")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	type Inner struct {
+		Port int
+	}
+
+	type Test struct {
+		Name    string
+		Inner   Inner
+		Tags    []string `hash:"set"`
+		Timeout *int
+	}
+
+	timeout := 10
+	v := Test{Name: "foo", Inner: Inner{Port: 80}, Tags: []string{"a", "b"}, Timeout: &timeout}
+	opts := &HashOptions{SkipNilPointers: true}
+	s, err := NewSnapshot(v, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Name     string
+		Mutate   func(v *Test)
+		Expected []string
+	}{
+		{
+			"unchanged",
+			func(v *Test) {},
+			nil,
+		},
+		{
+			"nested field",
+			func(v *Test) { v.Inner.Port = 443 },
+			[]string{"Inner"},
+		},
+		{
+			"reordered set",
+			func(v *Test) { v.Tags = []string{"b", "a"} },
+			nil,
+		},
+		{
+			"two fields",
+			func(v *Test) { v.Name = "bar"; v.Tags = []string{"c"} },
+			[]string{"Name", "Tags"},
+		},
+		{
+			"removed field",
+			func(v *Test) { v.Timeout = nil },
+			[]string{"Timeout"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			current := v
+			tc.Mutate(&current)
+			changed, err := s.Changed(current)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(changed, tc.Expected) {
+				t.Fatalf("bad changed fields: %#v, expected %#v", changed, tc.Expected)
+			}
+		})
+	}
+}
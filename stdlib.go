@@ -0,0 +1,78 @@
+package hashstructure
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	locationType = reflect.TypeOf(time.Location{})
+)
+
+// visitStdlib hashes v if it is one of the standard library types of
+// StdlibCanonicalization, and returns false otherwise.
+func (w *walker) visitStdlib(v reflect.Value) (uint64, bool, error) {
+	if !w.stdlibCanonicalization || !v.IsValid() || !v.CanInterface() || !w.isStdlibType(v.Type()) {
+		return 0, false, nil
+	}
+
+	var s string
+	switch t := v.Type(); t {
+	case timeType:
+		return w.hashTime(v.Interface().(time.Time)), true, nil
+	case durationType:
+		s = v.Interface().(time.Duration).String()
+	case locationType:
+		// Only a pointer to the Local location initializes it, not a copy
+		p := v
+		if p.CanAddr() {
+			p = p.Addr()
+		} else {
+			p = reflect.New(t)
+			p.Elem().Set(v)
+		}
+		s = p.Interface().(*time.Location).String()
+	default:
+		value, _ := sqlNullValue(t)
+		if !v.FieldByName("Valid").Bool() {
+			return w.hashNil(t), true, nil
+		}
+		h, err := w.visit(v.Field(value), visitOpts{})
+		return h, err == nil, err
+	}
+
+	h, err := w.visitValue(reflect.ValueOf(s), visitOpts{})
+	return h, err == nil, err
+}
+
+// isStdlibType returns whether visitStdlib hashes values of type t.
+func (w *walker) isStdlibType(t reflect.Type) bool {
+	switch t {
+	case timeType:
+		// NormalizeTime and TimeZoneSensitive are more specific, so they
+		// take precedence
+		return !w.normalizeTime && !w.timeZoneSensitive
+	case durationType, locationType:
+		return true
+	}
+	_, ok := sqlNullValue(t)
+	return ok
+}
+
+// sqlNullValue returns the index of the value field if t is one of the
+// Null types of database/sql, which have the value and a Valid field. The
+// types are matched by their shape, so that the generic sql.Null is
+// covered too.
+func sqlNullValue(t reflect.Type) (int, bool) {
+	if t.Kind() != reflect.Struct || t.PkgPath() != "database/sql" ||
+		!strings.HasPrefix(t.Name(), "Null") || t.NumField() != 2 {
+		return 0, false
+	}
+	valid, ok := t.FieldByName("Valid")
+	if !ok || valid.Type.Kind() != reflect.Bool {
+		return 0, false
+	}
+	return 1 - valid.Index[0], true
+}
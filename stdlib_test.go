@@ -0,0 +1,83 @@
+package hashstructure
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestHash_stdlibCanonicalization(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	type Test struct {
+		Name    sql.NullString
+		Created time.Time
+		Timeout time.Duration
+	}
+
+	opts := &HashOptions{StdlibCanonicalization: true}
+	cases := []struct {
+		One, Two interface{}
+		Opts     *HashOptions
+		Match    bool
+	}{
+		// Null types hash like their value, or like nil if it isn't valid
+		{sql.NullString{String: "foo", Valid: true}, "foo", opts, true},
+		{sql.NullString{String: "foo"}, sql.NullString{String: "bar"}, opts, true},
+		{sql.NullString{}, sql.NullString{Valid: true}, opts, false},
+		{sql.NullInt64{Int64: 1, Valid: true}, int64(1), opts, true},
+		{sql.NullInt64{Int64: 1, Valid: true}, sql.NullInt64{Int64: 2, Valid: true}, opts, false},
+		{sql.NullTime{Time: now, Valid: true}, sql.NullTime{Time: now.In(tokyo), Valid: true}, opts, true},
+		{sql.NullString{String: "foo"}, sql.NullString{String: "bar"}, nil, false},
+
+		// Times hash by their instant
+		{now, now.In(tokyo), opts, true},
+		{now, now.Add(time.Nanosecond), opts, false},
+		{now, now.In(tokyo), &HashOptions{StdlibCanonicalization: true, TimeZoneSensitive: true}, false},
+		{now, now.Add(time.Hour), nil, true},
+
+		// Durations like their text, locations like their name
+		{90 * time.Second, "1m30s", opts, true},
+		{time.Second, time.Minute, opts, false},
+		{[]time.Duration{time.Second}, []string{"1s"}, &HashOptions{StdlibCanonicalization: true, BulkNumbers: true}, true},
+		{time.UTC, "UTC", opts, true},
+		{tokyo, time.FixedZone("KST", 9*60*60), opts, false},
+		{tokyo, time.FixedZone("KST", 9*60*60), nil, true},
+
+		// Within structs
+		{
+			Test{Name: sql.NullString{String: "foo", Valid: true}, Created: now, Timeout: time.Second},
+			Test{Name: sql.NullString{String: "foo", Valid: true}, Created: now.In(tokyo), Timeout: time.Second},
+			opts,
+			true,
+		},
+		{
+			Test{Name: sql.NullString{String: "foo", Valid: true}, Created: now},
+			Test{Name: sql.NullString{String: "foo", Valid: true}, Created: now.Add(time.Hour)},
+			opts,
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		one, err := Hash(tc.One, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.One, err)
+		}
+		two, err := Hash(tc.Two, tc.Opts)
+		if err != nil {
+			t.Fatalf("Failed to hash %#v: %s", tc.Two, err)
+		}
+
+		// Zero is always wrong
+		if one == 0 {
+			t.Fatalf("zero hash: %#v", tc.One)
+		}
+
+		// Compare
+		if (one == two) != tc.Match {
+			t.Fatalf("bad, expected: %#v\n\n%#v\n\n%#v (case %d)", tc.Match, tc.One, tc.Two, i)
+		}
+	}
+}
@@ -0,0 +1,735 @@
+package hashstructure
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// fieldAcc accumulates the hashes of the fields of a struct, including the
+// fields of flattened embedded structs.
+type fieldAcc struct {
+	h uint64
+	n int
+
+	// texts are the canonical texts of the fields, which are only
+	// collected for Canonical.
+	texts []string
+
+	// hashes are the field hashes with OrderedFields, which are combined
+	// once all the fields are added.
+	hashes []uint64
+
+	// order is the field order from FieldOrderFunc, if any. The fields are
+	// then kept until they are combined in that order.
+	order  []string
+	fields []orderedField
+
+	// record, if set, records the hash of every field by its name, for
+	// FieldHashes.
+	record map[string]uint64
+
+	// index is the index of the flattened embedded struct being visited
+	// within the struct of type top, or nil for the fields of top.
+	top   reflect.Type
+	index []int
+}
+
+// orderedField is a field kept to be combined in the order of
+// FieldOrderFunc.
+type orderedField struct {
+	name string
+	h    uint64
+	text string
+}
+
+// visitFields adds the fields of the struct v to acc. If onlyFields is
+// true, only the fields listed in OnlyFields are added, including the
+// listed fields promoted from embedded structs with FlattenEmbedded.
+func (w *walker) visitFields(v reflect.Value, onlyFields bool, acc *fieldAcc) error {
+	if w.includeUnexported && !v.CanAddr() {
+		// Unexported fields can only be made readable through their
+		// address, so hash an addressable copy.
+		p := reflect.New(v.Type()).Elem()
+		p.Set(v)
+		v = p
+	}
+
+	parent := v.Interface()
+	var include Includable
+	if impl, ok := parent.(Includable); ok {
+		include = impl
+	}
+
+	t := v.Type()
+	protoMessage := w.protoMessages && isProtoMessage(t)
+
+	l := v.NumField()
+	for i := 0; i < l; i++ {
+		fieldType := t.Field(i)
+		if innerV := v.Field(i); v.CanSet() || fieldType.Name != "_" {
+			var f visitFlag
+			if fieldType.PkgPath != "" {
+				// Unexported
+				if !w.includeUnexported || fieldType.Name == "_" {
+					continue
+				}
+				innerV = reflect.NewAt(fieldType.Type, unsafe.Pointer(innerV.UnsafeAddr())).Elem()
+			}
+
+			tag, transforms := parseTag(fieldType.Tag.Get(w.tag))
+			if tag == "ignore" || tag == "-" {
+				// Ignore this field
+				continue
+			}
+
+			if strings.HasPrefix(tag, "oneof=") {
+				active, err := oneOfActive(v, w.tag, fieldType.Name, strings.TrimPrefix(tag, "oneof="))
+				if err != nil {
+					if err := w.fieldError(fieldType.Name, err); err != nil {
+						return err
+					}
+					continue
+				}
+				if !active {
+					// Not the active field of the union, so hash it as
+					// if it were removed like an ignored field
+					continue
+				}
+			}
+
+			if !fieldType.Anonymous && acc.shadowed(fieldType.Name, i) {
+				// Hidden by a field of the same name
+				continue
+			}
+
+			if protoMessage && isProtoBookkeeping(fieldType) {
+				// Generated protobuf bookkeeping
+				continue
+			}
+
+			// An embedded struct that isn't listed is still flattened
+			// with FlattenEmbedded, keeping the listed promoted fields
+			promoted := false
+			if onlyFields {
+				if _, ok := w.onlyFields[fieldType.Name]; !ok {
+					if !w.flattenEmbedded || !fieldType.Anonymous {
+						continue
+					}
+					promoted = true
+				}
+			}
+
+			if w.skipNilPointers && innerV.Kind() == reflect.Ptr && innerV.IsNil() {
+				continue
+			}
+
+			if w.skipNilInterfaces && innerV.Kind() == reflect.Interface && innerV.IsNil() {
+				continue
+			}
+
+			if w.jsonOmitEmpty && jsonOmitted(fieldType, innerV) {
+				continue
+			}
+
+			incl, err := w.selfIncluded(innerV)
+			if err != nil {
+				if err := w.fieldError(fieldType.Name, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if !incl {
+				continue
+			}
+
+			// if string is set, use the string value
+			if tag == "string" {
+				innerV, err = stringValue(w.tag, fieldType.Name, innerV)
+				if err != nil {
+					if err := w.fieldError(fieldType.Name, err); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			// if method is set, use the result of the method
+			if strings.HasPrefix(tag, "method:") {
+				innerV, err = callMethod(v, w.tag, fieldType.Name, strings.TrimPrefix(tag, "method:"))
+				if err != nil {
+					if err := w.fieldError(fieldType.Name, err); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			// if call is set, use the result of the function
+			if tag == "call" {
+				innerV, err = callField(innerV, w.tag, fieldType.Name)
+				if err != nil {
+					if err := w.fieldError(fieldType.Name, err); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			// transform strings, or the strings held by slices and arrays
+			if len(transforms) > 0 {
+				innerV, err = transformStrings(w.tag, fieldType.Name, innerV, transforms)
+				if err != nil {
+					if err := w.fieldError(fieldType.Name, err); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			// if lenonly is set, use the length
+			if tag == "lenonly" {
+				switch innerV.Kind() {
+				case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
+					innerV = reflect.ValueOf(innerV.Len())
+				default:
+					err := fmt.Errorf("hashstructure: %s has %s:\"lenonly\" set, but is a %s", fieldType.Name, w.tag, innerV.Kind())
+					if err := w.fieldError(fieldType.Name, err); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			// Check the global field filter
+			if w.fieldFilter != nil {
+				incl, err := w.fieldFilter(t, fieldType, innerV)
+				if err != nil {
+					if err := w.fieldError(fieldType.Name, err); err != nil {
+						return err
+					}
+					continue
+				}
+				if !incl {
+					continue
+				}
+			}
+
+			// Check if we implement includable and check it
+			if include != nil {
+				var incl bool
+				err := callSafely(fieldType.Name, "HashInclude", func() (err error) {
+					incl, err = include.HashInclude(fieldType.Name, innerV)
+					return err
+				})
+				if err != nil {
+					if err := w.fieldError(fieldType.Name, err); err != nil {
+						return err
+					}
+					continue
+				}
+				if !incl {
+					continue
+				}
+			}
+
+			// Embedded structs are either flattened into this struct or
+			// hashed as a nil sentinel if they are a nil pointer.
+			if embedded, ok := w.embeddedStruct(fieldType, tag, innerV); ok {
+				if embedded.IsValid() {
+					if w.trackPath {
+						w.pushPath("." + fieldType.Name)
+					}
+					index := acc.index
+					if index == nil {
+						acc.top = t
+					}
+					acc.index = append(index[:len(index):len(index)], i)
+					err := w.visitFields(embedded, promoted, acc)
+					acc.index = index
+					w.popPath()
+					if err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if promoted {
+				continue
+			}
+
+			switch tag {
+			case "set":
+				f |= visitFlagSet
+			case "revset":
+				f |= visitFlagRevSet
+			}
+
+			name := w.fieldName(fieldType)
+			kh, err := w.hashFieldName(name)
+			if err != nil {
+				return err
+			}
+
+			var vh uint64
+			if fieldType.Anonymous && (innerV.Kind() == reflect.Interface || innerV.Kind() == reflect.Ptr) &&
+				innerV.IsNil() && !(innerV.Kind() == reflect.Ptr && w.zeronil) {
+				// A nil embedded interface or pointer has no value, so
+				// fold a sentinel instead of the zero value.
+				vh = w.hashNil(fieldType.Type)
+			} else {
+				// if hasher is set, hash the field with that hasher
+				hasher := w.h
+				if strings.HasPrefix(tag, "hasher:") {
+					w.h, err = namedHasher(strings.TrimPrefix(tag, "hasher:"))
+					if err != nil {
+						w.h = hasher
+						if err := w.fieldError(fieldType.Name, err); err != nil {
+							return err
+						}
+						continue
+					}
+				}
+
+				if w.trackPath {
+					w.pushPath("." + fieldType.Name)
+				}
+				vh, err = w.visit(innerV, visitOpts{
+					Flags:       f,
+					Struct:      parent,
+					StructField: fieldType.Name,
+				})
+				w.popPath()
+				w.h = hasher
+				if err != nil {
+					if err := w.fieldError(fieldType.Name, err); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			w.addField(acc, fieldType.Name, name, kh, vh)
+		}
+	}
+
+	return nil
+}
+
+// shadowed returns whether the field called name, at index i of the
+// flattened embedded struct being visited, is hidden by Go's rules for
+// promoted fields: by a field of the same name at a shallower depth, or by
+// another one at the same depth.
+func (acc *fieldAcc) shadowed(name string, i int) bool {
+	if acc.index == nil {
+		return false
+	}
+
+	field, ok := acc.top.FieldByName(name)
+	if !ok || len(field.Index) != len(acc.index)+1 || field.Index[len(acc.index)] != i {
+		return true
+	}
+	for j, x := range acc.index {
+		if field.Index[j] != x {
+			return true
+		}
+	}
+	return false
+}
+
+// addField adds the field called goName, which is hashed as name with the
+// name hash kh and value hash vh, to acc. With FieldSalting, vh is salted
+// by goName first.
+func (w *walker) addField(acc *fieldAcc, goName, name string, kh, vh uint64) {
+	var text string
+	if w.canonical {
+		text = name + ": " + w.text
+	}
+
+	if w.fieldSalting {
+		w.h.Reset()
+		_, _ = w.h.Write([]byte(goName))
+		vh = w.hashUpdateOrdered(w.hashUpdateOrdered(fieldSaltSeed, w.h.Sum64()), vh)
+	}
+
+	acc.n++
+	fieldHash := w.hashUpdateOrdered(kh, vh)
+	if acc.record != nil {
+		acc.record[goName] = fieldHash
+	}
+	switch {
+	case acc.order != nil:
+		acc.fields = append(acc.fields, orderedField{name: goName, h: fieldHash, text: text})
+		return
+	case w.orderedFields:
+		acc.hashes = append(acc.hashes, fieldHash)
+	default:
+		acc.h = w.hashUpdateUnordered(acc.h, fieldHash)
+	}
+	if w.canonical {
+		acc.texts = append(acc.texts, text)
+	}
+}
+
+// visitGetters adds the results of the getters of the struct v to acc for
+// UseGetters. If onlyFields is true, only the getters listed in OnlyFields
+// are added.
+func (w *walker) visitGetters(v reflect.Value, onlyFields bool, acc *fieldAcc) error {
+	// Copy the struct so that getters with pointer receivers are found
+	// too, even if v isn't addressable.
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	t := p.Type()
+
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if m.Type.NumIn() != 1 || m.Type.NumOut() != 1 || !w.isGetter(m.Name) {
+			continue
+		}
+		if onlyFields {
+			if _, ok := w.onlyFields[m.Name]; !ok {
+				continue
+			}
+		}
+
+		var result reflect.Value
+		err := callSafely("", m.Name, func() error {
+			result = p.Method(i).Call(nil)[0]
+			return nil
+		})
+		if err != nil {
+			if err := w.fieldError(m.Name, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := w.canonicalFieldName(m.Name)
+		kh, err := w.hashFieldName(name)
+		if err != nil {
+			return err
+		}
+
+		if w.trackPath {
+			w.pushPath("." + m.Name)
+		}
+		vh, err := w.visit(result, visitOpts{})
+		w.popPath()
+		if err != nil {
+			if err := w.fieldError(m.Name, err); err != nil {
+				return err
+			}
+			continue
+		}
+		w.addField(acc, m.Name, name, kh, vh)
+	}
+
+	return nil
+}
+
+// isGetter returns whether the method of the given name is a getter with
+// UseGetters.
+func (w *walker) isGetter(name string) bool {
+	if w.getterFilter != nil {
+		return w.getterFilter(name)
+	}
+	return strings.HasPrefix(name, "Get")
+}
+
+// stringTransforms are the transforms of strings that can follow the
+// other tag values, as in hash:"set,lower".
+var stringTransforms = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+// parseTag splits the value of a hash tag into the tag value and the
+// names of the string transforms that follow it. A tag can also consist
+// of transforms only, such as hash:"lower".
+func parseTag(s string) (string, []string) {
+	// Most tags have no transforms, so don't allocate for them
+	if strings.IndexByte(s, ',') < 0 {
+		if _, ok := stringTransforms[s]; !ok {
+			return s, nil
+		}
+	}
+
+	parts := strings.Split(s, ",")
+	if _, ok := stringTransforms[parts[0]]; ok {
+		return "", parts
+	}
+	return parts[0], parts[1:]
+}
+
+// oneOfActive returns whether the field of the struct v is active for
+// hash:"oneof=Field" or hash:"oneof=Field:value1|value2", by the value of
+// its sibling Field. The field is active if the string of that value is
+// one of the listed values, or the name of the field otherwise, ignoring
+// case.
+func oneOfActive(v reflect.Value, tag, field, spec string) (bool, error) {
+	name, values := spec, []string{field}
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		name, values = spec[:i], strings.Split(spec[i+1:], "|")
+	}
+
+	sibling := v.FieldByName(name)
+	if !sibling.IsValid() {
+		return false, fmt.Errorf("hashstructure: %s has %s:\"oneof=%s\" set, but there is no field %s", field, tag, spec, name)
+	}
+
+	var s string
+	if sibling.CanInterface() {
+		s = fmt.Sprint(sibling.Interface())
+	} else {
+		s = fmt.Sprint(sibling)
+	}
+	for _, value := range values {
+		if strings.EqualFold(s, value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// transformStrings applies the string transforms of the field holding v to
+// it. v must be a string or a slice or array of strings.
+func transformStrings(tag, field string, v reflect.Value, transforms []string) (reflect.Value, error) {
+	fns := make([]func(string) string, len(transforms))
+	for i, name := range transforms {
+		fn, ok := stringTransforms[name]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("hashstructure: %s has unknown %s tag option %q", field, tag, name)
+		}
+		fns[i] = fn
+	}
+	transform := func(s string) string {
+		for _, fn := range fns {
+			s = fn(s)
+		}
+		return s
+	}
+
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.String:
+		result := reflect.New(t).Elem()
+		result.SetString(transform(v.String()))
+		return result, nil
+
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.String:
+		var result reflect.Value
+		if t.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return v, nil
+			}
+			result = reflect.MakeSlice(t, v.Len(), v.Len())
+		} else {
+			result = reflect.New(t).Elem()
+		}
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).SetString(transform(v.Index(i).String()))
+		}
+		return result, nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("hashstructure: %s has string transforms, but is a %s", field, t)
+}
+
+// fieldName returns the name that is hashed for field, which is the name
+// from the FieldNameFromTag tag if it has one, made canonical with
+// canonicalFieldName.
+func (w *walker) fieldName(field reflect.StructField) string {
+	name := field.Name
+	if w.fieldNameFromTag != "" {
+		tag := strings.SplitN(field.Tag.Get(w.fieldNameFromTag), ",", 2)[0]
+		if tag != "" && tag != "-" {
+			name = tag
+		}
+	}
+	return w.canonicalFieldName(name)
+}
+
+// canonicalFieldName returns the name hashed for a field or getter named
+// name, which is its alias in FieldAliases if it has one, normalized with
+// NormalizeFieldNames.
+func (w *walker) canonicalFieldName(name string) string {
+	if alias, ok := w.fieldAliases[name]; ok {
+		name = alias
+	}
+	if w.normalizeFieldNames != nil {
+		name = w.normalizeFieldNames(name)
+	}
+	return name
+}
+
+// hashFieldName returns the hash of the field name name, with
+// FieldNameHasher if set.
+func (w *walker) hashFieldName(name string) (uint64, error) {
+	if w.fieldNameHasher != nil {
+		return w.fieldNameHasher(name), nil
+	}
+	return w.hashName(name)
+}
+
+// stringValue returns the string that the field holding v is hashed as with
+// hash:"string". String takes precedence over GoString.
+func stringValue(tag, field string, v reflect.Value) (reflect.Value, error) {
+	var s string
+	var err error
+	switch impl := v.Interface().(type) {
+	case fmt.Stringer:
+		err = callSafely(field, "String", func() error {
+			s = impl.String()
+			return nil
+		})
+	case fmt.GoStringer:
+		err = callSafely(field, "GoString", func() error {
+			s = impl.GoString()
+			return nil
+		})
+	default:
+		err := &ErrNotStringer{Field: field}
+		if tag != "hash" {
+			err.Tag = tag
+		}
+		return reflect.Value{}, err
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(s), nil
+}
+
+// fieldsDone returns the hash of the struct with the given type name once
+// all its fields are added to acc.
+func (w *walker) fieldsDone(name string, acc *fieldAcc) uint64 {
+	switch {
+	case acc.order != nil:
+		rank := make(map[string]int, len(acc.order))
+		for i, name := range acc.order {
+			if _, ok := rank[name]; !ok {
+				rank[name] = i
+			}
+		}
+		fieldRank := func(name string) int {
+			if i, ok := rank[name]; ok {
+				return i
+			}
+			return len(acc.order)
+		}
+		sort.SliceStable(acc.fields, func(i, j int) bool {
+			return fieldRank(acc.fields[i].name) < fieldRank(acc.fields[j].name)
+		})
+
+		for _, f := range acc.fields {
+			acc.h = w.hashUpdateOrdered(acc.h, f.h)
+			if w.canonical {
+				acc.texts = append(acc.texts, f.text)
+			}
+		}
+
+	case w.orderedFields:
+		sort.Slice(acc.hashes, func(i, j int) bool { return acc.hashes[i] < acc.hashes[j] })
+		for _, fieldHash := range acc.hashes {
+			acc.h = w.hashUpdateOrdered(acc.h, fieldHash)
+		}
+	}
+
+	if w.canonical {
+		w.text = canonicalList(name+"{", acc.texts, "}", acc.order == nil)
+	}
+	return acc.h
+}
+
+// hashMethodSet returns the hash of the exported methods of the struct
+// type t and its pointer type, for IncludeMethodSet. The methods are in
+// the sorted order of reflect, and each is hashed by its name and its
+// signature without the receiver.
+func (w *walker) hashMethodSet(t reflect.Type) uint64 {
+	pt := reflect.PtrTo(t)
+	var h uint64
+	for i := 0; i < pt.NumMethod(); i++ {
+		m := pt.Method(i)
+		in := make([]reflect.Type, m.Type.NumIn()-1)
+		for j := range in {
+			in[j] = m.Type.In(j + 1)
+		}
+		out := make([]reflect.Type, m.Type.NumOut())
+		for j := range out {
+			out[j] = m.Type.Out(j)
+		}
+		sig := reflect.FuncOf(in, out, m.Type.IsVariadic())
+
+		w.h.Reset()
+		_, _ = w.h.Write([]byte(m.Name + " " + sig.String()))
+		h = w.hashUpdateOrdered(h, w.h.Sum64())
+	}
+	return h
+}
+
+// embeddedStruct returns whether the field holding v is an embedded struct
+// that is flattened with FlattenEmbedded, and the struct value to flatten.
+// The value is invalid for a nil pointer that must be hashed as nil.
+func (w *walker) embeddedStruct(field reflect.StructField, tag string, v reflect.Value) (reflect.Value, bool) {
+	if !w.flattenEmbedded || !field.Anonymous || tag != "" {
+		return reflect.Value{}, false
+	}
+
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || ((w.normalizeTime || w.timeZoneSensitive) && t == timeType) || w.interfaceHandler(v) != nil {
+		return reflect.Value{}, false
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if !v.IsNil() {
+			return v.Elem(), true
+		}
+		if w.zeronil {
+			return reflect.Zero(t), true
+		}
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+// jsonOmitted returns whether encoding/json omits the field holding v
+// because it is tagged omitempty and v is empty.
+func jsonOmitted(field reflect.StructField, v reflect.Value) bool {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return false
+	}
+
+	omitEmpty := false
+	opts := strings.Split(tag, ",")
+	for _, opt := range opts[1:] {
+		omitEmpty = omitEmpty || opt == "omitempty"
+	}
+	return omitEmpty && isEmptyValue(v)
+}
+
+// isEmptyValue returns whether v is empty by the rules of isEmptyValue in
+// encoding/json: false, 0, a nil pointer or interface, or an empty array,
+// map, slice or string.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package hashstructure
+
+import "reflect"
+
+// Node is a value in the tree returned by HashTree.
+type Node struct {
+	// Hash is the hash of the value, including its children. Values that
+	// aren't hashed on their own, such as the fields of a flattened
+	// embedded struct or slices nested in slices with Iterative, have a
+	// zero Hash.
+	Hash uint64
+
+	// Children are the nodes of the values within the value, keyed by
+	// ".Field" for struct fields, "[0]" for slice and array elements and
+	// "[key]" for map entries. It is nil if there are none.
+	Children map[string]*Node
+}
+
+// HashTree hashes v like Hash and returns the hash of every value within
+// it as a tree, like a Merkle tree. The hash of the root node is the same
+// as Hash returns, and the hash of every other node is the hash of that
+// value as it is combined into its parent. Comparing the trees of two
+// values shows which branch changed, since a changed value only changes
+// the nodes along its path.
+//
+// The options are the same as for Hash.
+func HashTree(v interface{}, opts *HashOptions) (*Node, error) {
+	w, err := newWalker(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Node{}
+	w.trackPath = true
+	w.onVisit = func(path []string, h uint64) {
+		n := root
+		for _, segment := range path {
+			c, ok := n.Children[segment]
+			if !ok {
+				if n.Children == nil {
+					n.Children = make(map[string]*Node)
+				}
+				c = &Node{}
+				n.Children[segment] = c
+			}
+			n = c
+		}
+		n.Hash = h
+	}
+	w.jsonFast = w.jsonFastPath()
+
+	h, err := w.visit(reflect.ValueOf(v), visitOpts{Flags: visitFlagRoot})
+	if err = w.finish(err); err != nil {
+		return nil, err
+	}
+	root.Hash = w.final(h)
+	return root, nil
+}
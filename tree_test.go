@@ -0,0 +1,96 @@
+package hashstructure
+
+import (
+	"testing"
+)
+
+func TestHashTree(t *testing.T) {
+	type Inner struct {
+		Enabled bool
+		Count   int
+	}
+	type Test struct {
+		Name  string
+		Inner Inner
+		Tags  []string
+		Meta  map[string]int
+	}
+
+	one := Test{
+		Name:  "foo",
+		Inner: Inner{Enabled: true, Count: 1},
+		Tags:  []string{"a", "b"},
+		Meta:  map[string]int{"x": 1, "y": 2},
+	}
+
+	for _, opts := range []*HashOptions{nil, {Version: 2}, {OrderedFields: true}} {
+		tree, err := HashTree(one, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		expected, err := Hash(one, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if tree.Hash != expected {
+			t.Fatalf("bad, expected root hash %d, got %d", expected, tree.Hash)
+		}
+	}
+
+	tree, err := HashTree(one, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, path := range [][]string{
+		{".Name"},
+		{".Inner", ".Enabled"},
+		{".Inner", ".Count"},
+		{".Tags", "[0]"},
+		{".Tags", "[1]"},
+		{".Meta", "[x]"},
+		{".Meta", "[y]"},
+	} {
+		n := tree
+		for _, segment := range path {
+			if n = n.Children[segment]; n == nil {
+				t.Fatalf("bad: no node at %v", path)
+			}
+		}
+		if n.Hash == 0 || n.Children != nil {
+			t.Fatalf("bad leaf at %v: %#v", path, n)
+		}
+	}
+
+	// A changed leaf only changes the nodes along its path
+	two := one
+	two.Inner.Count = 2
+	changed, err := HashTree(two, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if changed.Hash == tree.Hash {
+		t.Fatal("bad: root hash didn't change")
+	}
+	for name, c := range changed.Children {
+		if (c.Hash != tree.Children[name].Hash) != (name == ".Inner") {
+			t.Fatalf("bad: only .Inner should change, checking %s", name)
+		}
+	}
+	inner := changed.Children[".Inner"]
+	if inner.Children[".Enabled"].Hash != tree.Children[".Inner"].Children[".Enabled"].Hash {
+		t.Fatal("bad: .Inner.Enabled changed")
+	}
+	if inner.Children[".Count"].Hash == tree.Children[".Inner"].Children[".Count"].Hash {
+		t.Fatal("bad: .Inner.Count didn't change")
+	}
+}
+
+func TestHashTree_error(t *testing.T) {
+	type Test struct {
+		Ch chan int
+	}
+
+	if _, err := HashTree(Test{}, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}